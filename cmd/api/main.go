@@ -3,15 +3,32 @@ package main
 import (
 	"context"
 	"djiroutine-go-clean-architecture/internal/http/routes"
+	_apiKeyRepository "djiroutine-go-clean-architecture/internal/modules/apikey/repository"
+	_apiKeyUsecase "djiroutine-go-clean-architecture/internal/modules/apikey/usercase"
+	_auditRepository "djiroutine-go-clean-architecture/internal/modules/audit/repository"
+	_auditUsecase "djiroutine-go-clean-architecture/internal/modules/audit/usercase"
+	"djiroutine-go-clean-architecture/internal/modules/auth"
+	_authRepository "djiroutine-go-clean-architecture/internal/modules/auth/repository"
 	_authUsecase "djiroutine-go-clean-architecture/internal/modules/auth/usercase"
+	_rbacRepository "djiroutine-go-clean-architecture/internal/modules/rbac/repository"
+	_rbacUsecase "djiroutine-go-clean-architecture/internal/modules/rbac/usercase"
+	"djiroutine-go-clean-architecture/internal/modules/user"
 	_userRepository "djiroutine-go-clean-architecture/internal/modules/user/repository"
 	_userUsecase "djiroutine-go-clean-architecture/internal/modules/user/usercase"
 	"djiroutine-go-clean-architecture/pkg/config"
+	"djiroutine-go-clean-architecture/pkg/errors"
+	"djiroutine-go-clean-architecture/pkg/helper"
 	"djiroutine-go-clean-architecture/pkg/logger"
 	"djiroutine-go-clean-architecture/pkg/sso"
+	"djiroutine-go-clean-architecture/pkg/store"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -19,6 +36,9 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 )
 
+// shutdownTimeout bounds how long in-flight requests may run after a shutdown signal
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	// Initialize logger
 	err := godotenv.Load()
@@ -29,29 +49,74 @@ func main() {
 	l := logger.L
 
 	// Load environment variables or configuration
-	clientID := os.Getenv("OAUTH_CLIENT_ID")
-	clientSecret := os.Getenv("OAUTH_CLIENT_SECRET")
-	redirectURI := os.Getenv("OAUTH_REDIRECT_URI")
-	environment := sso.Environment(os.Getenv("OAUTH_ENVIRONMENT"))
 	redisURL := os.Getenv("REDIS_URL")
 
+	// Language of error messages for clients without a supported Accept-Language (en or id)
+	if lang := os.Getenv("APP_LANGUAGE"); lang != "" && !errors.SetDefaultLanguage(lang) {
+		l.Warn("Unsupported APP_LANGUAGE %q, using %s", lang, errors.DefaultLanguage())
+	}
+
 	timeout, _ := strconv.Atoi(os.Getenv("APP_TIMEOUT"))
 	timeoutContext := time.Duration(timeout) * time.Second
 
-	if environment == "" {
-		environment = sso.Sandbox // Default to sandbox
+	cacheTTL, _ := strconv.Atoi(os.Getenv("AUTH_CACHE_TTL"))
+	if cacheTTL == 0 {
+		cacheTTL = 300
 	}
 
-	// Initialize OAuth client
-	oauthClient, err := sso.NewOAuth2Client(
-		clientID,
-		clientSecret,
-		redirectURI,
-		environment,
-		redisURL,
-	)
-	if err != nil {
-		log.Fatalf("Failed to initialize OAuth client: %v", err)
+	opaqueCacheTTL, _ := strconv.Atoi(os.Getenv("AUTH_OPAQUE_CACHE_TTL"))
+	revocationTTL, _ := strconv.Atoi(os.Getenv("AUTH_REVOCATION_TTL"))
+
+	authConfig := auth.Config{
+		CacheTTL:       time.Duration(cacheTTL) * time.Second,
+		OpaqueCacheTTL: time.Duration(opaqueCacheTTL) * time.Second,
+		RevocationTTL:  time.Duration(revocationTTL) * time.Second,
+		Session:        auth.DefaultSessionConfig,
+	}
+	authConfig.Session.Enabled, _ = strconv.ParseBool(os.Getenv("AUTH_SESSION_MODE"))
+	if cookieName := os.Getenv("AUTH_SESSION_COOKIE"); cookieName != "" {
+		authConfig.Session.CookieName = cookieName
+	}
+	if secure, err := strconv.ParseBool(os.Getenv("AUTH_COOKIE_SECURE")); err == nil {
+		authConfig.Session.Secure = secure
+	}
+	switch strings.ToLower(os.Getenv("AUTH_COOKIE_SAMESITE")) {
+	case "strict":
+		authConfig.Session.SameSite = http.SameSiteStrictMode
+	case "none":
+		authConfig.Session.SameSite = http.SameSiteNoneMode
+	}
+	if sessionTTL, _ := strconv.Atoi(os.Getenv("AUTH_SESSION_TTL")); sessionTTL > 0 {
+		authConfig.Session.TTL = time.Duration(sessionTTL) * time.Second
+	}
+
+	authConfig.State = auth.DefaultStateConfig
+	authConfig.State.Secret = []byte(os.Getenv("AUTH_STATE_SECRET"))
+	if len(authConfig.State.Secret) == 0 {
+		// Random secret only works for a single instance; set AUTH_STATE_SECRET when scaling out
+		authConfig.State.Secret = []byte(helper.GenerateState())
+		l.Warn("AUTH_STATE_SECRET is not set, using a random secret")
+	}
+	if allowList := os.Getenv("AUTH_REDIRECT_ALLOWLIST"); allowList != "" {
+		authConfig.State.RedirectAllowList = strings.Split(allowList, ",")
+	}
+
+	authConfig.Impersonation = auth.DefaultImpersonationConfig
+	if impersonationTTL, _ := strconv.Atoi(os.Getenv("AUTH_IMPERSONATION_TTL")); impersonationTTL > 0 {
+		authConfig.Impersonation.TTL = time.Duration(impersonationTTL) * time.Second
+	}
+	if impersonationMaxTTL, _ := strconv.Atoi(os.Getenv("AUTH_IMPERSONATION_MAX_TTL")); impersonationMaxTTL > 0 {
+		authConfig.Impersonation.MaxTTL = time.Duration(impersonationMaxTTL) * time.Second
+	}
+
+	// Initialize OAuth providers
+	ssoTimeout, _ := strconv.Atoi(os.Getenv("OAUTH_HTTP_TIMEOUT"))
+	ssoRetries, _ := strconv.Atoi(os.Getenv("OAUTH_HTTP_RETRIES"))
+	ssoOptions := []sso.ClientOption{
+		sso.WithRetry(ssoRetries, 200*time.Millisecond),
+	}
+	if ssoTimeout > 0 {
+		ssoOptions = append(ssoOptions, sso.WithTimeout(time.Duration(ssoTimeout)*time.Second))
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -76,23 +141,94 @@ func main() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
+	// Initialize state/session store (redis, memory or postgres)
+	if redisURL == "" {
+		redisURL = sso.DefaultConfig.DefaultRedisURL
+	}
+	storeDriver := os.Getenv("STORE_DRIVER")
+	stateStore, err := config.NewStore(ctx, storeDriver, redisURL, mainDbService)
+	if err != nil {
+		log.Fatalf("Failed to initialize store: %v", err)
+	}
+
+	// The token cache is written on every cache miss, so it stays on memory or redis
+	cacheStore := stateStore
+	switch cacheDriver := os.Getenv("AUTH_CACHE_DRIVER"); {
+	case cacheDriver == store.DriverPostgres:
+		log.Fatalf("AUTH_CACHE_DRIVER must be redis or memory")
+	case cacheDriver != "":
+		cacheStore, err = config.NewStore(ctx, cacheDriver, redisURL, nil)
+		if err != nil {
+			log.Fatalf("Failed to initialize token cache: %v", err)
+		}
+	case storeDriver == store.DriverPostgres:
+		cacheStore = store.NewMemoryStore()
+	}
+
+	// Initialize OAuth providers; PKCE verifiers and nonces share the store above
+	ssoOptions = append(ssoOptions, sso.WithStore(stateStore))
+	providers := sso.NewRegistry()
+	for _, providerConfig := range sso.LoadProviderConfigs(os.Getenv) {
+		oauthClient, err := sso.NewOAuth2ClientFromConfig(providerConfig, redisURL, ssoOptions...)
+		if err != nil {
+			log.Fatalf("Failed to initialize OAuth provider %s: %v", providerConfig.Name, err)
+		}
+		if err := providers.Register(oauthClient); err != nil {
+			log.Fatalf("Failed to register OAuth provider %s: %v", providerConfig.Name, err)
+		}
+	}
+
 	// Initialize Echo
 	e := echo.New()
 
+	// The client IP recorded in the audit log is only read from X-Forwarded-For when the
+	// request comes through a trusted proxy; otherwise clients could forge it
+	ipExtractor, err := newIPExtractor(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	e.IPExtractor = ipExtractor
+
 	// Add standard middleware
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORS())
 
 	// Initialize use cases
-	authUseCase := _authUsecase.NewAuthUseCase(oauthClient)
+	linkByEmail, _ := strconv.ParseBool(os.Getenv("AUTH_LINK_BY_EMAIL"))
+	provisioningConfig := user.ProvisioningConfig{
+		LinkByEmail: linkByEmail,
+	}
+	if providers := os.Getenv("AUTH_LINK_TRUSTED_PROVIDERS"); providers != "" {
+		provisioningConfig.LinkTrustedProviders = strings.Split(providers, ",")
+	}
+	if domains := os.Getenv("AUTH_LINK_EMAIL_DOMAINS"); domains != "" {
+		provisioningConfig.LinkEmailDomains = strings.Split(domains, ",")
+	}
 
 	userRepo := _userRepository.NewUserRepository(mainDbService, l)
-	userUsecase := _userUsecase.NewUserUsecase(userRepo, timeoutContext, l)
+	userUsecase := _userUsecase.NewUserUsecase(userRepo, timeoutContext, provisioningConfig, l)
+
+	// Audit log ditulis di background; event yang masih antre di-flush saat aplikasi berhenti
+	auditBuffer, _ := strconv.Atoi(os.Getenv("AUDIT_BUFFER_SIZE"))
+	auditRepo := _auditRepository.NewAuditRepository(mainDbService, l)
+	auditUsecase := _auditUsecase.NewAuditUsecase(auditRepo, timeoutContext, auditBuffer, l)
+
+	authRepo := _authRepository.NewAuthRepository(stateStore, cacheStore, l)
+	authUseCase := _authUsecase.NewAuthUseCase(providers, authRepo, userUsecase, auditUsecase, authConfig, l)
+
+	rbacRepo := _rbacRepository.NewRBACRepository(mainDbService, l)
+	rbacUsecase := _rbacUsecase.NewRBACUsecase(rbacRepo, timeoutContext, l)
+
+	apiKeyRepo := _apiKeyRepository.NewAPIKeyRepository(mainDbService, l)
+	apiKeyUsecase := _apiKeyUsecase.NewAPIKeyUsecase(apiKeyRepo, rbacUsecase, timeoutContext, l)
 
 	useCases := map[string]interface{}{
-		"auth": authUseCase,
-		"user": userUsecase,
+		"auth":   authUseCase,
+		"user":   userUsecase,
+		"rbac":   rbacUsecase,
+		"apikey": apiKeyUsecase,
+		"audit":  auditUsecase,
 	}
 
 	// Setup routes
@@ -104,7 +240,70 @@ func main() {
 		port = "8080"
 	}
 
-	if err := e.Start(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// Stop on SIGINT/SIGTERM: finish in-flight requests, then flush the audit log before the
+	// database is closed. log.Fatalf is not used from here on because it skips this cleanup.
+	signalCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- e.Start(":" + port)
+	}()
+
+	exitCode := 0
+	select {
+	case err := <-serverErr:
+		if err != http.ErrServerClosed {
+			l.Error("Failed to start server: %v", err)
+			exitCode = 1
+		}
+	case <-signalCtx.Done():
+		l.Info("Shutting down server")
 	}
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		l.Error("Failed to shut down server: %v", err)
+		exitCode = 1
+	}
+
+	auditUsecase.Close()
+	mainDbService.Close()
+
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
+
+// newIPExtractor uses the connection address when trustedProxies is empty, and otherwise the
+// last X-Forwarded-For address not added by one of trustedProxies, a comma separated list of
+// IPs or CIDRs
+func newIPExtractor(trustedProxies string) (echo.IPExtractor, error) {
+	if strings.TrimSpace(trustedProxies) == "" {
+		return echo.ExtractIPDirect(), nil
+	}
+
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, proxy := range strings.Split(trustedProxies, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, echo.TrustIPRange(ipNet))
+	}
+
+	return echo.ExtractIPFromXFFHeader(options...), nil
 }
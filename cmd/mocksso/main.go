@@ -0,0 +1,61 @@
+// Command mocksso runs the ssotest mock OAuth2/OIDC provider for local development.
+//
+// Point the API at it with OAUTH_DISCOVERY_URL=http://localhost:9096 and matching
+// OAUTH_CLIENT_ID/OAUTH_CLIENT_SECRET.
+package main
+
+import (
+	"djiroutine-go-clean-architecture/pkg/sso/ssotest"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":9096", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9096", "external base URL of the provider")
+	clientID := flag.String("client-id", "local-client", "accepted client_id")
+	clientSecret := flag.String("client-secret", "local-secret", "accepted client_secret")
+	redirectURIs := flag.String("redirect-uris", "", "comma separated allowed redirect URIs; empty allows any")
+	usersFile := flag.String("users", "", "JSON file with an array of {sub, email, name, profile}")
+	tokenTTL := flag.Duration("token-ttl", time.Hour, "access token lifetime")
+	jwtTokens := flag.Bool("jwt", false, "issue signed JWT access tokens")
+	rotate := flag.Bool("rotate", false, "rotate refresh tokens on every refresh")
+	flag.Parse()
+
+	config := ssotest.Config{
+		ClientID:            *clientID,
+		ClientSecret:        *clientSecret,
+		AccessTokenTTL:      *tokenTTL,
+		JWTAccessTokens:     *jwtTokens,
+		RotateRefreshTokens: *rotate,
+		RequirePKCE:         true,
+	}
+	if *redirectURIs != "" {
+		config.RedirectURIs = strings.Split(*redirectURIs, ",")
+	}
+
+	if *usersFile != "" {
+		data, err := os.ReadFile(*usersFile)
+		if err != nil {
+			log.Fatalf("Failed to read users file: %v", err)
+		}
+		if err := json.Unmarshal(data, &config.Users); err != nil {
+			log.Fatalf("Failed to parse users file: %v", err)
+		}
+	}
+
+	server, err := ssotest.New(*issuer, config)
+	if err != nil {
+		log.Fatalf("Failed to create mock SSO: %v", err)
+	}
+
+	log.Printf("Mock SSO listening on %s (issuer %s)", *addr, server.URL())
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		log.Fatalf("Failed to start mock SSO: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package entity
+
+import (
+	"strings"
+	"time"
+)
+
+type APIKey struct {
+	ID         int        `gorm:"primaryKey;column:id"`
+	Name       string     `gorm:"column:name"`
+	OwnerID    int        `gorm:"column:owner_id"`
+	Prefix     string     `gorm:"column:prefix"`
+	KeyHash    string     `gorm:"column:key_hash"`
+	Scopes     string     `gorm:"column:scopes"`
+	ExpiresAt  *time.Time `gorm:"column:expires_at"`
+	LastUsedAt *time.Time `gorm:"column:last_used_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at"`
+	CreatedAt  time.Time  `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (APIKey) TableName() string {
+	return "api_key"
+}
+
+// ScopeList returns the space separated scopes as a slice
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+// Active reports whether the key is neither revoked nor expired at now
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// MappingToResponse never exposes the key hash
+func (k *APIKey) MappingToResponse() *APIKeyResponse {
+	return &APIKeyResponse{
+		ID:         k.ID,
+		Name:       k.Name,
+		OwnerID:    k.OwnerID,
+		Prefix:     k.Prefix,
+		Scopes:     k.ScopeList(),
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+		CreatedAt:  k.CreatedAt,
+	}
+}
+
+type APIKeyResponse struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	OwnerID    int        `json:"owner_id"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// APIKeySecretResponse is returned once on create and rotate; the plain key cannot be read again
+type APIKeySecretResponse struct {
+	*APIKeyResponse
+	Key string `json:"key"`
+}
+
+// request
+type APIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	OwnerID   int        `json:"owner_id" validate:"min=0"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (request *APIKeyRequest) MappingToAPIKey() *APIKey {
+	return &APIKey{
+		Name:      request.Name,
+		OwnerID:   request.OwnerID,
+		Scopes:    strings.Join(request.Scopes, " "),
+		ExpiresAt: request.ExpiresAt,
+	}
+}
@@ -0,0 +1,39 @@
+package entity
+
+type Role struct {
+	ID          int     `gorm:"primaryKey;column:id"`
+	Name        string  `gorm:"column:name"`
+	Description *string `gorm:"column:description"`
+}
+
+func (Role) TableName() string {
+	return "rbac_role"
+}
+
+type Permission struct {
+	ID          int     `gorm:"primaryKey;column:id"`
+	Code        string  `gorm:"column:code"`
+	Description *string `gorm:"column:description"`
+}
+
+func (Permission) TableName() string {
+	return "rbac_permission"
+}
+
+type RolePermission struct {
+	RoleID       int `gorm:"primaryKey;column:role_id"`
+	PermissionID int `gorm:"primaryKey;column:permission_id"`
+}
+
+func (RolePermission) TableName() string {
+	return "rbac_role_permission"
+}
+
+type UserRole struct {
+	UserID int `gorm:"primaryKey;column:user_id"`
+	RoleID int `gorm:"primaryKey;column:role_id"`
+}
+
+func (UserRole) TableName() string {
+	return "rbac_user_role"
+}
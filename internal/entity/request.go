@@ -1,63 +1,11 @@
 package entity
 
-import (
-	"djiroutine-go-clean-architecture/pkg"
-	"djiroutine-go-clean-architecture/pkg/helper"
-)
-
 // request
 type RequestList struct {
-	Page   *int    `json:"page"`
-	Limit  *int    `json:"limit"`
+	Page   *int    `json:"page" query:"page" default:"1" validate:"min=1"`
+	Limit  *int    `json:"limit" query:"limit" default:"100" validate:"min=1,max=200"`
 	Offset *int    `json:"offset"`
-	Search *string `json:"search"`
-}
-
-func (request *RequestList) MappingToGlobalValidation() pkg.GlobalValidation {
-	res := pkg.GlobalValidation{
-		RequiredValidation: []pkg.RequiredValidation{
-			{
-				Key:   "Page",
-				Value: helper.IntToString(*request.Page),
-			},
-			{
-				Key:   "Limit",
-				Value: helper.IntToString(*request.Limit),
-			},
-		},
-		DataTypeNumberIntValidation: []pkg.DataTypeNumberIntValidation{
-			{
-				Key:   "Page",
-				Value: helper.IntToString(*request.Page),
-			},
-			{
-				Key:   "Limit",
-				Value: helper.IntToString(*request.Limit),
-			}},
-		DataTypeNumberFloatValidation: []pkg.DataTypeNumberFloatValidation{},
-		PageLimitValidation: []pkg.PageLimitValidation{
-			{
-				Key:   "Page",
-				Value: helper.IntToString(*request.Page),
-			},
-			{
-				Key:   "Limit",
-				Value: helper.IntToString(*request.Limit),
-			},
-		},
-		MaxMinNumberValidation: []pkg.MaxMinNumberValidation{},
-	}
-
-	return res
-}
-
-func (req RequestList) MappingDefaultPage() *RequestList {
-	res := &RequestList{
-		Limit:  helper.IntToIntNullable(100),
-		Page:   helper.IntToIntNullable(1),
-		Offset: helper.IntToIntNullable(0),
-	}
-	return res
+	Search *string `json:"search" query:"search"`
 }
 
 // param
@@ -1,21 +1,250 @@
 package entity
 
+import (
+	"djiroutine-go-clean-architecture/pkg"
+	"djiroutine-go-clean-architecture/pkg/errors"
+	"djiroutine-go-clean-architecture/pkg/helper"
+	"djiroutine-go-clean-architecture/pkg/query"
+	stderrors "errors"
+	"strings"
+	"time"
+)
+
 type User struct {
-	ID        int     `gorm:"primaryKey;column:id"`
-	Username  string  `gorm:"column:username"`
-	Email     string  `gorm:"column:email"`
-	FirstName *string `gorm:"column:first_name"`
-	LastName  *string `gorm:"column:last_name"`
+	ID        int       `gorm:"primaryKey;column:id"`
+	Username  string    `gorm:"column:username"`
+	Email     string    `gorm:"column:email"`
+	FirstName *string   `gorm:"column:first_name"`
+	LastName  *string   `gorm:"column:last_name"`
+	CreatedAt time.Time `gorm:"column:created_at"`
 }
 
 type UserResponse struct {
-	ID        int     `gorm:"primaryKey;column:id" json:"id"`
-	Username  string  `gorm:"column:username" json:"username"`
-	Email     string  `gorm:"column:email" json:"email"`
-	FirstName *string `gorm:"column:first_name" json:"first_name"`
-	Lastname  *string `gorm:"column:last_name" json:"last_name"`
+	ID        int       `gorm:"primaryKey;column:id" json:"id"`
+	Username  string    `gorm:"column:username" json:"username"`
+	Email     string    `gorm:"column:email" json:"email"`
+	FirstName *string   `gorm:"column:first_name" json:"first_name"`
+	Lastname  *string   `gorm:"column:last_name" json:"last_name"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
 }
 
 func (User) TableName() string {
 	return "auth_user"
 }
+
+// request
+type UserRequest struct {
+	Username  string  `json:"username" validate:"required"`
+	Email     string  `json:"email" validate:"required,email"`
+	FirstName *string `json:"first_name"`
+	LastName  *string `json:"last_name"`
+}
+
+func (request *UserRequest) MappingToUser() *User {
+	return &User{
+		Username:  request.Username,
+		Email:     request.Email,
+		FirstName: request.FirstName,
+		LastName:  request.LastName,
+	}
+}
+
+// MappingToUpdateFields returns every column so that a full update also clears omitted names
+func (request *UserRequest) MappingToUpdateFields() map[string]interface{} {
+	return map[string]interface{}{
+		"username":   request.Username,
+		"email":      request.Email,
+		"first_name": request.FirstName,
+		"last_name":  request.LastName,
+	}
+}
+
+type UserPatchRequest struct {
+	Username  *string `json:"username"`
+	Email     *string `json:"email" validate:"email"`
+	FirstName *string `json:"first_name"`
+	LastName  *string `json:"last_name"`
+}
+
+func (request *UserPatchRequest) MappingToGlobalValidation() pkg.GlobalValidation {
+	res := pkg.GlobalValidation{}
+
+	if request.Username != nil {
+		res.RequiredValidation = append(res.RequiredValidation, pkg.RequiredValidation{
+			Key:   "username",
+			Value: *request.Username,
+		})
+	}
+
+	if request.Email != nil {
+		res.RequiredValidation = append(res.RequiredValidation, pkg.RequiredValidation{
+			Key:   "email",
+			Value: *request.Email,
+		})
+	}
+
+	return res
+}
+
+// MappingToUpdateFields returns only the columns present in the request body
+func (request *UserPatchRequest) MappingToUpdateFields() map[string]interface{} {
+	fields := map[string]interface{}{}
+
+	if request.Username != nil {
+		fields["username"] = *request.Username
+	}
+
+	if request.Email != nil {
+		fields["email"] = *request.Email
+	}
+
+	if request.FirstName != nil {
+		fields["first_name"] = request.FirstName
+	}
+
+	if request.LastName != nil {
+		fields["last_name"] = request.LastName
+	}
+
+	return fields
+}
+
+// UserIdentity links a local user to an account at an external identity provider
+type UserIdentity struct {
+	ID          int        `gorm:"primaryKey;column:id"`
+	UserID      int        `gorm:"column:user_id"`
+	Provider    string     `gorm:"column:provider"`
+	Subject     string     `gorm:"column:subject"`
+	Email       *string    `gorm:"column:email"`
+	CreatedAt   time.Time  `gorm:"column:created_at"`
+	LastLoginAt *time.Time `gorm:"column:last_login_at"`
+}
+
+func (UserIdentity) TableName() string {
+	return "user_identity"
+}
+
+// ExternalUser is the identity asserted by an external provider after login
+type ExternalUser struct {
+	Provider string
+	Subject  string
+	Email    string
+	Name     string
+	// EmailVerified is the provider's email_verified claim
+	EmailVerified bool
+}
+
+// MappingToUser builds a new local user; the username is derived from the email local part, or the subject
+func (external *ExternalUser) MappingToUser() *User {
+	username := external.Subject
+	if i := strings.Index(external.Email, "@"); i > 0 {
+		username = external.Email[:i]
+	}
+
+	user := &User{
+		Username: username,
+		Email:    external.Email,
+	}
+	external.applyName(user)
+
+	return user
+}
+
+// applyName splits the display name into first and last name
+func (external *ExternalUser) applyName(user *User) {
+	fields := strings.Fields(external.Name)
+	if len(fields) == 0 {
+		return
+	}
+
+	firstName := fields[0]
+	user.FirstName = &firstName
+
+	if len(fields) > 1 {
+		lastName := strings.Join(fields[1:], " ")
+		user.LastName = &lastName
+	}
+}
+
+// UserSortColumns maps the fields GET /api/users can be sorted by to their columns
+var UserSortColumns = map[string]string{
+	"id":         "id",
+	"username":   "username",
+	"email":      "email",
+	"first_name": "first_name",
+	"last_name":  "last_name",
+	"created_at": "created_at",
+}
+
+// UserRequestList is the query of GET /api/users, e.g.
+// ?sort=-created_at,username&email=a@b.com&id__in=1,2&created_at__gte=2024-01-01
+type UserRequestList struct {
+	RequestList
+	// Sort is a comma separated list of UserSortColumns, prefixed with "-" for descending order
+	Sort         *string `json:"sort" query:"sort"`
+	Email        *string `json:"email" query:"email"`
+	Username     *string `json:"username" query:"username"`
+	IDIn         []int   `json:"id__in" query:"id__in" validate:"max=100"`
+	CreatedAtGte *string `json:"created_at__gte" query:"created_at__gte" validate:"date=rfc3339|2006-01-02"`
+	CreatedAtLte *string `json:"created_at__lte" query:"created_at__lte" validate:"date=rfc3339|2006-01-02"`
+}
+
+// ValidateFields checks sort against UserSortColumns
+func (request *UserRequestList) ValidateFields() []errors.FieldError {
+	var appErr *errors.AppError
+	if _, err := query.ParseSort("sort", helper.StringNullableToString(request.Sort), UserSortColumns); stderrors.As(err, &appErr) {
+		return appErr.Fields
+	}
+	return nil
+}
+
+// MappingToFilter converts a validated request into the filter applied to both the page and the total
+func (request *UserRequestList) MappingToFilter() *UserFilter {
+	filter := &UserFilter{
+		Search:   request.Search,
+		Email:    request.Email,
+		Username: request.Username,
+		IDs:      request.IDIn,
+	}
+	filter.Sort, _ = query.ParseSort("sort", helper.StringNullableToString(request.Sort), UserSortColumns)
+
+	if request.CreatedAtGte != nil {
+		if from, _, ok := parseDateTime(*request.CreatedAtGte); ok {
+			filter.CreatedFrom = &from
+		}
+	}
+	if request.CreatedAtLte != nil {
+		if to, dateOnly, ok := parseDateTime(*request.CreatedAtLte); ok {
+			// A date without time includes the whole day
+			if dateOnly {
+				to = to.AddDate(0, 0, 1).Add(-time.Microsecond)
+			}
+			filter.CreatedTo = &to
+		}
+	}
+
+	return filter
+}
+
+// UserFilter selects users for ListUsers; nil fields are not filtered on
+type UserFilter struct {
+	// Search matches first or last name
+	Search      *string
+	Email       *string
+	Username    *string
+	IDs         []int
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Sort        []query.Sort
+}
+
+// parseDateTime parses an RFC 3339 timestamp or a date, reporting whether it was a date only
+func parseDateTime(value string) (time.Time, bool, bool) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, false, true
+	}
+	if parsed, err := time.Parse("2006-01-02", value); err == nil {
+		return parsed, true, true
+	}
+	return time.Time{}, false, false
+}
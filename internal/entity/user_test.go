@@ -0,0 +1,93 @@
+package entity_test
+
+import (
+	"djiroutine-go-clean-architecture/internal/entity"
+	"djiroutine-go-clean-architecture/pkg/query"
+	"djiroutine-go-clean-architecture/pkg/validator"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestUserRequestListValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		request entity.UserRequestList
+		fields  []string
+	}{
+		{name: "no filters", request: entity.UserRequestList{}},
+		{name: "allowed sort", request: entity.UserRequestList{Sort: stringPtr("-created_at,username")}},
+		{name: "sort outside the whitelist", request: entity.UserRequestList{Sort: stringPtr("password")}, fields: []string{"sort"}},
+		{name: "date filters", request: entity.UserRequestList{CreatedAtGte: stringPtr("2024-01-01"), CreatedAtLte: stringPtr("2024-01-31T10:00:00Z")}},
+		{name: "malformed date", request: entity.UserRequestList{CreatedAtGte: stringPtr("01/01/2024")}, fields: []string{"created_at__gte"}},
+		{name: "too many ids", request: entity.UserRequestList{IDIn: make([]int, 101)}, fields: []string{"id__in"}},
+		{
+			name:    "every error is reported",
+			request: entity.UserRequestList{Sort: stringPtr("-password"), CreatedAtLte: stringPtr("yesterday")},
+			fields:  []string{"created_at__lte", "sort"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := validator.Fields(&tt.request)
+			if err != nil {
+				t.Fatalf("Fields: %v", err)
+			}
+			var got []string
+			for _, field := range fields {
+				got = append(got, field.Field)
+			}
+			if !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("fields = %v, want %v", got, tt.fields)
+			}
+		})
+	}
+}
+
+func TestUserRequestListMappingToFilter(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	endOfDay := time.Date(2024, 1, 31, 23, 59, 59, 999999000, time.UTC)
+	exact := time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		request entity.UserRequestList
+		want    entity.UserFilter
+	}{
+		{name: "no filters", request: entity.UserRequestList{}},
+		{
+			name:    "equality filters and ids",
+			request: entity.UserRequestList{Email: stringPtr("a@b.com"), Username: stringPtr("jane"), IDIn: []int{1, 2}},
+			want:    entity.UserFilter{Email: stringPtr("a@b.com"), Username: stringPtr("jane"), IDs: []int{1, 2}},
+		},
+		{
+			name:    "sort",
+			request: entity.UserRequestList{Sort: stringPtr("-created_at,username")},
+			want:    entity.UserFilter{Sort: []query.Sort{{Column: "created_at", Desc: true}, {Column: "username"}}},
+		},
+		{
+			name:    "date upper bound includes the whole day",
+			request: entity.UserRequestList{CreatedAtGte: stringPtr("2024-01-01"), CreatedAtLte: stringPtr("2024-01-31")},
+			want:    entity.UserFilter{CreatedFrom: &from, CreatedTo: &endOfDay},
+		},
+		{
+			name:    "timestamp upper bound is exact",
+			request: entity.UserRequestList{CreatedAtLte: stringPtr("2024-01-31T10:00:00Z")},
+			want:    entity.UserFilter{CreatedTo: &exact},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.request.MappingToFilter()
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("MappingToFilter = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
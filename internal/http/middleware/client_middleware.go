@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"djiroutine-go-clean-architecture/internal/modules/audit"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ClientInfo menyimpan IP dan user agent klien di context request agar use case
+// (misalnya audit log) bisa membacanya tanpa bergantung pada echo
+func ClientInfo(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		ctx := audit.WithClient(req.Context(), audit.Client{
+			IP:        c.RealIP(),
+			UserAgent: req.UserAgent(),
+		})
+		c.SetRequest(req.WithContext(ctx))
+
+		return next(c)
+	}
+}
@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"djiroutine-go-clean-architecture/pkg"
+	"djiroutine-go-clean-architecture/pkg/errors"
+	"djiroutine-go-clean-architecture/pkg/helper"
+	"djiroutine-go-clean-architecture/pkg/logger"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// ErrorIDHeader berisi ID error yang juga tercatat di log, untuk dikutip saat melapor ke tim support
+const ErrorIDHeader = "X-Error-ID"
+
+// resolvedError adalah error yang sudah diterjemahkan menjadi bagian-bagian respons
+type resolvedError struct {
+	status  int
+	code    string
+	message string
+	fields  []errors.FieldError
+	// generic berarti message bukan tulisan handler, misalnya teks status HTTP atau sentinel error
+	generic bool
+}
+
+// NewHTTPErrorHandler membuat echo.HTTPErrorHandler yang merender semua error dalam satu
+// format, sehingga handler cukup mengembalikan error (return err). Klien yang mengirim
+// "Accept: application/problem+json" menerima RFC 7807, klien lain tetap menerima pkg.Response.
+// Error asal dari error 5xx tidak dikirim ke klien, hanya dicatat di log bersama ID error.
+func NewHTTPErrorHandler(log logger.Logger) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		if c.Response().Committed {
+			return
+		}
+
+		errID := helper.ErrId()
+		resolved := resolveError(err)
+		if resolved.status >= http.StatusInternalServerError {
+			log.Error("["+errID+"]  http.middleware.HTTPErrorHandler: %s %s: %s", c.Request().Method, c.Path(), err.Error())
+		}
+
+		lang := errors.NegotiateLanguage(c.Request().Header.Get("Accept-Language"))
+		resolved = resolved.localize(lang)
+
+		c.Response().Header().Set(ErrorIDHeader, errID)
+		c.Response().Header().Set("Content-Language", lang)
+
+		if c.Request().Method == http.MethodHead {
+			err = c.NoContent(resolved.status)
+		} else if acceptsProblem(c.Request()) {
+			problem := new(pkg.Problem)
+			problem.MappingProblem(resolved.status, resolved.code, resolved.message, c.Request().URL.Path, errID, resolved.fields)
+			err = writeProblem(c, problem)
+		} else {
+			response := new(pkg.Response)
+			response.MappingResponseError(resolved.status, resolved.message)
+			err = c.JSON(resolved.status, response)
+		}
+		if err != nil {
+			log.Error("http.middleware.HTTPErrorHandler: %s", err.Error())
+		}
+	}
+}
+
+// localize menerjemahkan pesan ke bahasa lang dengan katalog di pkg/errors. Pesan field
+// selalu diambil dari katalog dan pesan error 5xx selalu pesan umum. Untuk bahasa lain, pesan
+// yang ditulis handler diterjemahkan jika ada di katalog, selain itu dikirim apa adanya agar
+// penyebabnya tidak hilang; hanya pesan generik yang diganti pesan umum kode error-nya.
+func (r resolvedError) localize(lang string) resolvedError {
+	if len(r.fields) > 0 {
+		r.fields = errors.LocalizeFields(lang, r.fields)
+		if r.code == errors.CodeValidationFailed {
+			messages := make([]string, 0, len(r.fields))
+			for _, field := range r.fields {
+				messages = append(messages, field.Message)
+			}
+			r.message = strings.Join(messages, ", ")
+		}
+		return r
+	}
+
+	if r.status >= http.StatusInternalServerError || (lang != errors.LanguageEnglish && r.generic) {
+		if message := errors.Message(lang, r.code); message != "" {
+			r.message = message
+		}
+		return r
+	}
+	if lang != errors.LanguageEnglish {
+		r.message = errors.Translate(lang, r.message)
+	}
+	return r
+}
+
+// acceptsProblem mengembalikan true jika klien meminta application/problem+json
+func acceptsProblem(r *http.Request) bool {
+	return strings.Contains(r.Header.Get(echo.HeaderAccept), pkg.ProblemContentType)
+}
+
+func writeProblem(c echo.Context, problem *pkg.Problem) error {
+	body, err := json.Marshal(problem)
+	if err != nil {
+		return err
+	}
+	return c.Blob(problem.Status, pkg.ProblemContentType, body)
+}
+
+// resolveError menentukan status HTTP, kode stabil dan pesan yang aman ditampilkan ke klien
+func resolveError(err error) resolvedError {
+	var (
+		appErr    *errors.AppError
+		httpErr   *echo.HTTPError
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+
+	switch {
+	case stderrors.As(err, &appErr):
+		if appErr.Message == "" {
+			return resolvedError{status: appErr.Code, code: errors.Code(err), message: http.StatusText(appErr.Code), fields: appErr.Fields, generic: true}
+		}
+		return resolvedError{status: appErr.Code, code: errors.Code(err), message: appErr.Message, fields: appErr.Fields}
+
+	case stderrors.As(err, &httpErr):
+		message := fmt.Sprint(httpErr.Message)
+		if httpErr.Internal != nil && httpErr.Code >= http.StatusInternalServerError {
+			message = http.StatusText(httpErr.Code)
+		}
+		return resolvedError{status: httpErr.Code, code: errors.CodeForStatus(httpErr.Code), message: message, generic: message == http.StatusText(httpErr.Code)}
+
+	case stderrors.Is(err, gorm.ErrRecordNotFound):
+		err = errors.ErrNotFound
+	case stderrors.Is(err, gorm.ErrDuplicatedKey):
+		err = errors.ErrConflict
+	case stderrors.Is(err, gorm.ErrForeignKeyViolated):
+		err = errors.ErrBadParamInput
+
+	// Body JSON yang rusak atau salah tipe dari helper.JsonDecode atau helper.JSONDecode;
+	// helper.Bind sudah mengembalikannya sebagai *errors.AppError
+	case stderrors.As(err, &syntaxErr), stderrors.As(err, &typeErr):
+		return resolvedError{status: http.StatusBadRequest, code: errors.CodeBadRequest, message: err.Error()}
+	}
+
+	status := helper.GetStatusCode(err)
+	if status >= http.StatusInternalServerError {
+		return resolvedError{status: status, code: errors.CodeInternal, message: errors.ErrInternalServerError.Error()}
+	}
+
+	return resolvedError{status: status, code: errors.Code(err), message: err.Error(), generic: true}
+}
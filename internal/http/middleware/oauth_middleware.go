@@ -1,22 +1,37 @@
 package middleware
 
 import (
+	"djiroutine-go-clean-architecture/internal/entity"
+	"djiroutine-go-clean-architecture/internal/modules/apikey"
+	"djiroutine-go-clean-architecture/internal/modules/audit"
 	"djiroutine-go-clean-architecture/internal/modules/auth"
 	"djiroutine-go-clean-architecture/pkg/errors"
-	"fmt"
-	"net/http"
+	"djiroutine-go-clean-architecture/pkg/logger"
+	"strconv"
 	"strings"
 
 	"github.com/labstack/echo/v4"
 )
 
+// APIKeyHeader adalah header alternatif untuk mengirim API key selain "Authorization: ApiKey {key}"
+const APIKeyHeader = "X-API-Key"
+
+// ImpersonatedByHeader ditambahkan ke setiap respons request yang di-impersonasi, berisi actor "provider:sub"
+const ImpersonatedByHeader = "X-Impersonated-By"
+
 type OAuthMiddleware struct {
-	AuthUseCase auth.UseCase
+	AuthUseCase   auth.UseCase
+	APIKeyUseCase apikey.UseCase
+	AuditUseCase  audit.UseCase
+	Log           logger.Logger
 }
 
-func NewOAuthMiddleware(authUseCase auth.UseCase) *OAuthMiddleware {
+func NewOAuthMiddleware(authUseCase auth.UseCase, apiKeyUseCase apikey.UseCase, auditUseCase audit.UseCase, log logger.Logger) *OAuthMiddleware {
 	return &OAuthMiddleware{
-		AuthUseCase: authUseCase,
+		AuthUseCase:   authUseCase,
+		APIKeyUseCase: apiKeyUseCase,
+		AuditUseCase:  auditUseCase,
+		Log:           log,
 	}
 }
 
@@ -24,33 +39,41 @@ func (m *OAuthMiddleware) Authenticate(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		authHeader := c.Request().Header.Get("Authorization")
 
+		// Klien mesin (batch job, service account) mengirim API key, bukan token OAuth
+		if key := apiKeyFromRequest(c, authHeader); key != "" && m.APIKeyUseCase != nil {
+			return m.authenticateAPIKey(c, next, key)
+		}
+
+		// Klien browser pada mode sesi mengirim cookie, bukan header Authorization
+		sessionConfig := m.AuthUseCase.SessionConfig()
+		if authHeader == "" && sessionConfig.Enabled {
+			if cookie, err := c.Cookie(sessionConfig.CookieName); err == nil && cookie.Value != "" {
+				return m.authenticateSession(c, next, sessionConfig, cookie.Value)
+			}
+		}
+
 		if authHeader == "" {
-			return c.JSON(http.StatusUnauthorized, map[string]string{
-				"error": "Authorization header is required",
-			})
+			return errors.AuthError("Authorization header is required", nil)
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			return c.JSON(http.StatusUnauthorized, map[string]string{
-				"error": "Authorization header format must be Bearer {token}",
-			})
+			return errors.AuthError("Authorization header format must be Bearer {token}", nil)
 		}
 
 		token := parts[1]
 
+		// Token impersonasi diterbitkan aplikasi ini sendiri, bukan oleh SSO
+		if auth.IsImpersonationToken(token) {
+			return m.authenticateImpersonation(c, next, token)
+		}
+
 		// Verifikasi token melalui use case
-		user, err := m.AuthUseCase.ValidateToken(c.Request().Context(), token)
-		fmt.Println(user)
+		provider := c.Request().Header.Get(auth.ProviderHeader)
+		user, err := m.AuthUseCase.ValidateToken(c.Request().Context(), provider, token)
 		if err != nil {
-			if appErr, ok := err.(*errors.AppError); ok {
-				return c.JSON(appErr.Code, map[string]string{
-					"error": appErr.Message,
-				})
-			}
-			return c.JSON(http.StatusUnauthorized, map[string]string{
-				"error": "Invalid or expired token",
-			})
+			m.recordRejected(c, provider, err)
+			return err
 		}
 
 		// Tambahkan user ke context
@@ -59,3 +82,102 @@ func (m *OAuthMiddleware) Authenticate(next echo.HandlerFunc) echo.HandlerFunc {
 		return next(c)
 	}
 }
+
+func (m *OAuthMiddleware) authenticateSession(c echo.Context, next echo.HandlerFunc, sessionConfig auth.SessionConfig, sessionID string) error {
+	session, err := m.AuthUseCase.ResolveSession(c.Request().Context(), sessionID)
+	if err != nil {
+		m.recordRejected(c, "", err)
+		return err
+	}
+
+	// Cookie dikirim otomatis oleh browser, jadi method yang mengubah data wajib membawa token CSRF
+	if !auth.IsSafeMethod(c.Request().Method) && !auth.ValidCSRFToken(session, c.Request().Header.Get(sessionConfig.CSRFHeaderName)) {
+		return errors.ForbiddenError("Invalid or missing CSRF token", nil)
+	}
+
+	user, err := m.AuthUseCase.ValidateToken(c.Request().Context(), session.User.Provider, session.Token.AccessToken)
+	if err != nil {
+		m.recordRejected(c, session.User.Provider, err)
+		return err
+	}
+
+	c.Set("user", user)
+
+	return next(c)
+}
+
+func (m *OAuthMiddleware) authenticateAPIKey(c echo.Context, next echo.HandlerFunc, key string) error {
+	user, err := m.APIKeyUseCase.Authenticate(c.Request().Context(), key)
+	if err != nil {
+		m.recordRejected(c, apikey.ProviderName, err)
+		return err
+	}
+
+	c.Set("user", user)
+
+	return next(c)
+}
+
+func (m *OAuthMiddleware) authenticateImpersonation(c echo.Context, next echo.HandlerFunc, token string) error {
+	log := "http.middleware.OAuthMiddleware.authenticateImpersonation: %s"
+
+	user, err := m.AuthUseCase.ResolveImpersonation(c.Request().Context(), token)
+	if err != nil {
+		m.recordRejected(c, auth.ImpersonationProvider, err)
+		return err
+	}
+
+	// Admin asli tetap tersedia di context di samping user yang di-impersonasi
+	c.Set("user", user)
+	c.Set("actor", user.Actor)
+	c.Response().Header().Set(ImpersonatedByHeader, user.ActorID())
+
+	requestLine := c.Request().Method + " " + c.Request().URL.RequestURI()
+	m.Log.Info(log, user.ActorID()+" as user "+user.ID+": "+requestLine)
+
+	err = next(c)
+
+	if m.AuditUseCase != nil {
+		m.AuditUseCase.Record(c.Request().Context(), &entity.AuditEvent{
+			EventType: entity.AuditEventImpersonatedRequest,
+			Provider:  user.Provider,
+			Subject:   user.ID,
+			Email:     user.Email,
+			Actor:     user.ActorID(),
+			Outcome:   entity.AuditOutcomeSuccess,
+			Detail:    requestLine + " " + strconv.Itoa(c.Response().Status),
+		})
+	}
+
+	return err
+}
+
+// apiKeyFromRequest mengambil API key dari header X-API-Key atau "Authorization: ApiKey {key}"
+func apiKeyFromRequest(c echo.Context, authHeader string) string {
+	if key := c.Request().Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) == 2 && parts[0] == "ApiKey" {
+		return parts[1]
+	}
+
+	return ""
+}
+
+// recordRejected mencatat kredensial yang ditolak ke audit log; header yang kosong atau
+// salah format tidak dicatat karena tidak membawa kredensial
+func (m *OAuthMiddleware) recordRejected(c echo.Context, provider string, err error) {
+	if m.AuditUseCase == nil {
+		return
+	}
+
+	reason := err.Error()
+	m.AuditUseCase.Record(c.Request().Context(), &entity.AuditEvent{
+		EventType: entity.AuditEventTokenInvalid,
+		Provider:  provider,
+		Outcome:   entity.AuditOutcomeFailure,
+		Reason:    &reason,
+	})
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"djiroutine-go-clean-architecture/internal/modules/auth"
+	"djiroutine-go-clean-architecture/internal/modules/rbac"
+	"djiroutine-go-clean-architecture/pkg/errors"
+	"djiroutine-go-clean-architecture/pkg/helper"
+	"djiroutine-go-clean-architecture/pkg/logger"
+
+	"github.com/labstack/echo/v4"
+)
+
+type PermissionMiddleware struct {
+	RBACUseCase rbac.UseCase
+	Log         logger.Logger
+}
+
+func NewPermissionMiddleware(rbacUseCase rbac.UseCase, log logger.Logger) *PermissionMiddleware {
+	return &PermissionMiddleware{
+		RBACUseCase: rbacUseCase,
+		Log:         log,
+	}
+}
+
+// RequirePermission hanya meneruskan request jika user di context memiliki permission tersebut.
+// Harus dipasang setelah OAuthMiddleware.Authenticate.
+func (m *PermissionMiddleware) RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			log := "http.middleware.PermissionMiddleware.RequirePermission: %s"
+
+			user, ok := c.Get("user").(*auth.User)
+			if !ok || user == nil {
+				return errors.ErrUnAuthorize
+			}
+
+			allowed, err := m.RBACUseCase.HasPermission(c.Request().Context(), user, permission)
+			if err != nil {
+				m.Log.Error("["+helper.ErrId()+"]  "+log, err.Error())
+
+				return err
+			}
+
+			if !allowed {
+				return errors.ErrForbidden
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -2,8 +2,13 @@ package routes
 
 import (
 	"djiroutine-go-clean-architecture/internal/http/middleware"
+	"djiroutine-go-clean-architecture/internal/modules/apikey"
+	apiKeyHandler "djiroutine-go-clean-architecture/internal/modules/apikey/handler"
+	"djiroutine-go-clean-architecture/internal/modules/audit"
+	auditHandler "djiroutine-go-clean-architecture/internal/modules/audit/handler"
 	"djiroutine-go-clean-architecture/internal/modules/auth"
 	authHandler "djiroutine-go-clean-architecture/internal/modules/auth/handler"
+	"djiroutine-go-clean-architecture/internal/modules/rbac"
 	"djiroutine-go-clean-architecture/internal/modules/user"
 	userHandler "djiroutine-go-clean-architecture/internal/modules/user/handler"
 	"djiroutine-go-clean-architecture/pkg/logger"
@@ -17,13 +22,33 @@ func SetupRoutes(e *echo.Echo, useCases map[string]interface{}) {
 		panic("Invalid auth use case provided")
 	}
 
+	apiKeyUseCase, ok := useCases["apikey"].(apikey.UseCase)
+	if !ok {
+		panic("Invalid apikey use case provided")
+	}
+
+	auditUseCase, ok := useCases["audit"].(audit.UseCase)
+	if !ok {
+		panic("Invalid audit use case provided")
+	}
+
 	authH := authHandler.NewAuthHandler(authUseCase)
 
-	oauthMiddleware := middleware.NewOAuthMiddleware(authUseCase)
+	oauthMiddleware := middleware.NewOAuthMiddleware(authUseCase, apiKeyUseCase, auditUseCase, logger.L)
+
+	// Semua error dari handler dan middleware dirender dalam satu format respons
+	e.HTTPErrorHandler = middleware.NewHTTPErrorHandler(logger.L)
+
+	// IP dan user agent dibutuhkan audit log pada semua route autentikasi
+	e.Use(middleware.ClientInfo)
 
 	authGroup := e.Group("/auth")
+	authGroup.GET("/providers", authH.Providers)
 	authGroup.GET("/login", authH.Login)
 	authGroup.GET("/callback", authH.Callback)
+	authGroup.GET("/:provider/login", authH.Login)
+	authGroup.GET("/:provider/callback", authH.Callback)
+	authGroup.POST("/refresh", authH.Refresh)
 	authGroup.POST("/logout", authH.Logout)
 
 	apiGroup := e.Group("/api")
@@ -33,15 +58,45 @@ func SetupRoutes(e *echo.Echo, useCases map[string]interface{}) {
 		return c.String(200, "Hello, World!")
 	})
 
-	setupUsersRoutes(apiGroup, useCases)
+	rbacUseCase, ok := useCases["rbac"].(rbac.UseCase)
+	if !ok {
+		panic("Invalid rbac use case provided")
+	}
+
+	permissionMiddleware := middleware.NewPermissionMiddleware(rbacUseCase, logger.L)
+
+	apiGroup.GET("/auth/cache-stats", authH.CacheStats, permissionMiddleware.RequirePermission(auth.CacheStatsPermission))
+
+	apiGroup.POST("/auth/logout-all", authH.LogoutAll)
+	apiGroup.POST("/auth/users/:sub/logout", authH.ForceLogout, permissionMiddleware.RequirePermission("sessions:revoke"))
+	apiGroup.POST("/auth/impersonate", authH.Impersonate, permissionMiddleware.RequirePermission(auth.ImpersonatePermission))
+
+	setupUsersRoutes(apiGroup, useCases, permissionMiddleware)
+	setupAPIKeysRoutes(apiGroup, apiKeyUseCase, permissionMiddleware)
+
+	auditH := auditHandler.NewAuditHandler(logger.L, auditUseCase)
+	apiGroup.GET("/audit/auth", auditH.ListEvents, permissionMiddleware.RequirePermission("audit:read"))
 }
 
-func setupUsersRoutes(g *echo.Group, useCases map[string]interface{}) {
+func setupUsersRoutes(g *echo.Group, useCases map[string]interface{}, pm *middleware.PermissionMiddleware) {
 	userUseCase, ok := useCases["user"].(user.UseCase)
 	if !ok {
 		panic("Invalid user use case provided")
 	}
 
 	userH := userHandler.NewUserHandler(logger.L, userUseCase)
-	g.GET("/users", userH.ListUsers)
+	g.GET("/users", userH.ListUsers, pm.RequirePermission("users:read"))
+	g.POST("/users", userH.CreateUser, pm.RequirePermission("users:write"))
+	g.GET("/users/:id", userH.GetUser, pm.RequirePermission("users:read"))
+	g.PUT("/users/:id", userH.UpdateUser, pm.RequirePermission("users:write"))
+	g.PATCH("/users/:id", userH.PatchUser, pm.RequirePermission("users:write"))
+	g.DELETE("/users/:id", userH.DeleteUser, pm.RequirePermission("users:write"))
+}
+
+func setupAPIKeysRoutes(g *echo.Group, apiKeyUseCase apikey.UseCase, pm *middleware.PermissionMiddleware) {
+	apiKeyH := apiKeyHandler.NewAPIKeyHandler(logger.L, apiKeyUseCase)
+	g.GET("/apikeys", apiKeyH.ListKeys, pm.RequirePermission("apikeys:read"))
+	g.POST("/apikeys", apiKeyH.CreateKey, pm.RequirePermission("apikeys:write"))
+	g.POST("/apikeys/:id/rotate", apiKeyH.RotateKey, pm.RequirePermission("apikeys:write"))
+	g.DELETE("/apikeys/:id", apiKeyH.RevokeKey, pm.RequirePermission("apikeys:write"))
 }
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"djiroutine-go-clean-architecture/internal/entity"
+	"djiroutine-go-clean-architecture/internal/modules/apikey"
+	"djiroutine-go-clean-architecture/internal/modules/auth"
+	"djiroutine-go-clean-architecture/pkg"
+	"djiroutine-go-clean-architecture/pkg/errors"
+	"djiroutine-go-clean-architecture/pkg/helper"
+	"djiroutine-go-clean-architecture/pkg/logger"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type APIKeyHandler struct {
+	Log           logger.Logger
+	APIKeyUsecase apikey.UseCase
+}
+
+func NewAPIKeyHandler(log logger.Logger, apiKeyUseCase apikey.UseCase) *APIKeyHandler {
+	return &APIKeyHandler{
+		Log:           log,
+		APIKeyUsecase: apiKeyUseCase,
+	}
+}
+
+func (h *APIKeyHandler) ListKeys(c echo.Context) error {
+	response := new(pkg.Response)
+
+	user, ok := c.Get("user").(*auth.User)
+	if !ok {
+		return errors.AuthError("Unauthorized", nil)
+	}
+
+	res, err := h.APIKeyUsecase.ListKeys(c.Request().Context(), user)
+	if err != nil {
+		return err
+	}
+
+	response.MappingResponseSuccess("Get API keys successfull", res)
+
+	return c.JSON(response.Code, response)
+}
+
+func (h *APIKeyHandler) CreateKey(c echo.Context) error {
+	response := new(pkg.Response)
+
+	request, err := helper.Bind[entity.APIKeyRequest](c)
+	if err != nil {
+		return err
+	}
+
+	user, ok := c.Get("user").(*auth.User)
+	if !ok {
+		return errors.AuthError("Unauthorized", nil)
+	}
+
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		return errors.FieldErr("expires_at", errors.ErrInvalidValue)
+	}
+
+	res, err := h.APIKeyUsecase.CreateKey(c.Request().Context(), user, request)
+	if err != nil {
+		return err
+	}
+
+	response.MappingResponseCreated("Create API key successfull", res)
+
+	return c.JSON(response.Code, response)
+}
+
+func (h *APIKeyHandler) RotateKey(c echo.Context) error {
+	response := new(pkg.Response)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.FieldErr("id", errors.ErrInvalidDataType)
+	}
+
+	user, ok := c.Get("user").(*auth.User)
+	if !ok {
+		return errors.AuthError("Unauthorized", nil)
+	}
+
+	res, err := h.APIKeyUsecase.RotateKey(c.Request().Context(), user, id)
+	if err != nil {
+		return err
+	}
+
+	response.MappingResponseSuccess("Rotate API key successfull", res)
+
+	return c.JSON(response.Code, response)
+}
+
+func (h *APIKeyHandler) RevokeKey(c echo.Context) error {
+	response := new(pkg.Response)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.FieldErr("id", errors.ErrInvalidDataType)
+	}
+
+	user, ok := c.Get("user").(*auth.User)
+	if !ok {
+		return errors.AuthError("Unauthorized", nil)
+	}
+
+	if err := h.APIKeyUsecase.RevokeKey(c.Request().Context(), user, id); err != nil {
+		return err
+	}
+
+	response.MappingResponseSuccess("Revoke API key successfull", nil)
+
+	return c.JSON(response.Code, response)
+}
@@ -0,0 +1,15 @@
+package apikey
+
+import (
+	"context"
+	"djiroutine-go-clean-architecture/internal/entity"
+)
+
+type Repository interface {
+	// ListAPIKeys returns the keys of ownerID, or every key when ownerID is 0
+	ListAPIKeys(ctx context.Context, ownerID int) ([]*entity.APIKey, error)
+	GetAPIKeyByID(ctx context.Context, id int) (*entity.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*entity.APIKey, error)
+	CreateAPIKey(ctx context.Context, key *entity.APIKey) error
+	UpdateAPIKey(ctx context.Context, id int, fields map[string]interface{}) error
+}
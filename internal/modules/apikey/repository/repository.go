@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"djiroutine-go-clean-architecture/internal/entity"
+	"djiroutine-go-clean-architecture/pkg/config"
+	"djiroutine-go-clean-architecture/pkg/errors"
+	"djiroutine-go-clean-architecture/pkg/logger"
+	goerrors "errors"
+
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository struct {
+	db  config.DBService
+	log logger.Logger
+}
+
+func NewAPIKeyRepository(db config.DBService, log logger.Logger) *APIKeyRepository {
+	return &APIKeyRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *APIKeyRepository) ListAPIKeys(ctx context.Context, ownerID int) ([]*entity.APIKey, error) {
+	log := "modules.apikey.repository.ListAPIKeys: %s"
+
+	db := r.db.GetConnection().WithContext(ctx)
+	if ownerID != 0 {
+		db = db.Where("owner_id = ?", ownerID)
+	}
+
+	var res []*entity.APIKey
+	err := db.Order("id").Find(&res).Error
+	if err != nil {
+		r.log.Error(log, err)
+
+		return nil, mapError(err)
+	}
+
+	return res, nil
+}
+
+func (r *APIKeyRepository) GetAPIKeyByID(ctx context.Context, id int) (*entity.APIKey, error) {
+	log := "modules.apikey.repository.GetAPIKeyByID: %s"
+
+	res := new(entity.APIKey)
+	err := r.db.GetConnection().WithContext(ctx).Where("id = ?", id).Take(res).Error
+	if err != nil {
+		if !goerrors.Is(err, gorm.ErrRecordNotFound) {
+			r.log.Error(log, err)
+		}
+
+		return nil, mapError(err)
+	}
+
+	return res, nil
+}
+
+func (r *APIKeyRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*entity.APIKey, error) {
+	log := "modules.apikey.repository.GetAPIKeyByHash: %s"
+
+	res := new(entity.APIKey)
+	err := r.db.GetConnection().WithContext(ctx).Where("key_hash = ?", keyHash).Take(res).Error
+	if err != nil {
+		if !goerrors.Is(err, gorm.ErrRecordNotFound) {
+			r.log.Error(log, err)
+		}
+
+		return nil, mapError(err)
+	}
+
+	return res, nil
+}
+
+func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, key *entity.APIKey) error {
+	log := "modules.apikey.repository.CreateAPIKey: %s"
+
+	err := r.db.GetConnection().WithContext(ctx).Create(key).Error
+	if err != nil {
+		r.log.Error(log, err)
+
+		return mapError(err)
+	}
+
+	return nil
+}
+
+func (r *APIKeyRepository) UpdateAPIKey(ctx context.Context, id int, fields map[string]interface{}) error {
+	log := "modules.apikey.repository.UpdateAPIKey: %s"
+
+	query := r.db.GetConnection().WithContext(ctx).Model(&entity.APIKey{}).Where("id = ?", id).Updates(fields)
+	if query.Error != nil {
+		r.log.Error(log, query.Error)
+
+		return mapError(query.Error)
+	}
+
+	if query.RowsAffected == 0 {
+		return errors.ErrNotFound
+	}
+
+	return nil
+}
+
+// mapError translates GORM errors into the sentinel errors understood by helper.GetStatusCode
+func mapError(err error) error {
+	switch {
+	case goerrors.Is(err, gorm.ErrRecordNotFound):
+		return errors.ErrNotFound
+	case goerrors.Is(err, gorm.ErrDuplicatedKey):
+		return errors.ErrConflict
+	case goerrors.Is(err, gorm.ErrForeignKeyViolated):
+		return errors.ErrBadParamInput
+	default:
+		return errors.ErrInternalServerError
+	}
+}
@@ -0,0 +1,36 @@
+package apikey
+
+import (
+	"context"
+	"djiroutine-go-clean-architecture/internal/entity"
+	"djiroutine-go-clean-architecture/internal/modules/auth"
+)
+
+const (
+	// ProviderName is set as auth.User.Provider for principals authenticated with an API key
+	ProviderName = "apikey"
+
+	// AdminPermission allows listing, creating, rotating and revoking keys of other users
+	AdminPermission = "apikeys:admin"
+)
+
+type UseCase interface {
+	// ListKeys returns the keys owned by caller, or every key when caller has AdminPermission
+	ListKeys(ctx context.Context, caller *auth.User) ([]*entity.APIKeyResponse, error)
+
+	// CreateKey issues a new key for caller; the plain key is only part of this response.
+	// The key scopes must be held by caller and never include "*"; a key owned by another
+	// user needs AdminPermission.
+	CreateKey(ctx context.Context, caller *auth.User, request *entity.APIKeyRequest) (*entity.APIKeySecretResponse, error)
+
+	// RotateKey replaces the secret of an active key, keeping its name, owner, scopes and expiry.
+	// Keys of other owners need AdminPermission and are reported as not found otherwise.
+	RotateKey(ctx context.Context, caller *auth.User, id int) (*entity.APIKeySecretResponse, error)
+
+	// RevokeKey revokes a key, with the same ownership rule as RotateKey
+	RevokeKey(ctx context.Context, caller *auth.User, id int) error
+
+	// Authenticate resolves a plain key to the principal the rest of the API sees.
+	// The principal is limited to the key scopes; roles of the owner are not inherited.
+	Authenticate(ctx context.Context, key string) (*auth.User, error)
+}
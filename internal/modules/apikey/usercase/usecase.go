@@ -0,0 +1,316 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"djiroutine-go-clean-architecture/internal/entity"
+	"djiroutine-go-clean-architecture/internal/modules/apikey"
+	"djiroutine-go-clean-architecture/internal/modules/auth"
+	"djiroutine-go-clean-architecture/internal/modules/rbac"
+	"djiroutine-go-clean-architecture/pkg/errors"
+	"djiroutine-go-clean-architecture/pkg/logger"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+const (
+	// keyPrefix marks API keys so they can be told apart from OAuth tokens and found by secret scanners
+	keyPrefix = "ak_"
+	// displayPrefixLength is how much of the key is stored in clear to identify it in listings
+	displayPrefixLength = len(keyPrefix) + 8
+	// lastUsedInterval limits last_used_at writes to one per key per interval
+	lastUsedInterval = time.Minute
+)
+
+type APIKeyUsecase struct {
+	apiKeyRepo     apikey.Repository
+	rbacUsecase    rbac.UseCase
+	contextTimeout time.Duration
+	log            logger.Logger
+}
+
+func NewAPIKeyUsecase(apiKeyRepo apikey.Repository, rbacUsecase rbac.UseCase, timeout time.Duration, log logger.Logger) apikey.UseCase {
+	return &APIKeyUsecase{
+		apiKeyRepo:     apiKeyRepo,
+		rbacUsecase:    rbacUsecase,
+		contextTimeout: timeout,
+		log:            log,
+	}
+}
+
+func (u *APIKeyUsecase) ListKeys(ctx context.Context, caller *auth.User) ([]*entity.APIKeyResponse, error) {
+	log := "modules.apikey.usecase.ListKeys: %s"
+
+	ctx, cancel := context.WithTimeout(ctx, u.contextTimeout)
+	defer cancel()
+
+	admin, err := u.isAdmin(ctx, caller)
+	if err != nil {
+		return nil, err
+	}
+
+	// Principals without a local user own no keys
+	ownerID := 0
+	if !admin {
+		if caller.LocalID == 0 {
+			return []*entity.APIKeyResponse{}, nil
+		}
+		ownerID = caller.LocalID
+	}
+
+	keys, err := u.apiKeyRepo.ListAPIKeys(ctx, ownerID)
+	if err != nil {
+		u.log.Error(log, err.Error())
+
+		return nil, err
+	}
+
+	res := make([]*entity.APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		res = append(res, key.MappingToResponse())
+	}
+
+	return res, nil
+}
+
+func (u *APIKeyUsecase) CreateKey(ctx context.Context, caller *auth.User, request *entity.APIKeyRequest) (*entity.APIKeySecretResponse, error) {
+	log := "modules.apikey.usecase.CreateKey: %s"
+
+	ctx, cancel := context.WithTimeout(ctx, u.contextTimeout)
+	defer cancel()
+
+	// Without owner_id the key belongs to the caller
+	if request.OwnerID == 0 {
+		request.OwnerID = caller.LocalID
+	}
+	if request.OwnerID == 0 {
+		return nil, errors.FieldErr("owner_id", errors.ErrIsRequired)
+	}
+
+	if err := u.authorizeKey(ctx, caller, request); err != nil {
+		return nil, err
+	}
+
+	plain, err := generateKey()
+	if err != nil {
+		u.log.Error(log, err.Error())
+
+		return nil, errors.ErrInternalServerError
+	}
+
+	key := request.MappingToAPIKey()
+	key.Prefix = plain[:displayPrefixLength]
+	key.KeyHash = hashKey(plain)
+
+	if err := u.apiKeyRepo.CreateAPIKey(ctx, key); err != nil {
+		u.log.Error(log, err.Error())
+
+		return nil, err
+	}
+
+	return &entity.APIKeySecretResponse{
+		APIKeyResponse: key.MappingToResponse(),
+		Key:            plain,
+	}, nil
+}
+
+func (u *APIKeyUsecase) RotateKey(ctx context.Context, caller *auth.User, id int) (*entity.APIKeySecretResponse, error) {
+	log := "modules.apikey.usecase.RotateKey: %s"
+
+	ctx, cancel := context.WithTimeout(ctx, u.contextTimeout)
+	defer cancel()
+
+	key, err := u.ownedKey(ctx, caller, id)
+	if err != nil {
+		u.log.Error(log, err.Error())
+
+		return nil, err
+	}
+
+	// Revoked keys stay revoked; create a new key instead
+	if key.RevokedAt != nil {
+		return nil, errors.ErrConflict
+	}
+
+	plain, err := generateKey()
+	if err != nil {
+		u.log.Error(log, err.Error())
+
+		return nil, errors.ErrInternalServerError
+	}
+
+	key.Prefix = plain[:displayPrefixLength]
+	key.KeyHash = hashKey(plain)
+	key.LastUsedAt = nil
+
+	err = u.apiKeyRepo.UpdateAPIKey(ctx, id, map[string]interface{}{
+		"prefix":       key.Prefix,
+		"key_hash":     key.KeyHash,
+		"last_used_at": nil,
+	})
+	if err != nil {
+		u.log.Error(log, err.Error())
+
+		return nil, err
+	}
+
+	return &entity.APIKeySecretResponse{
+		APIKeyResponse: key.MappingToResponse(),
+		Key:            plain,
+	}, nil
+}
+
+func (u *APIKeyUsecase) RevokeKey(ctx context.Context, caller *auth.User, id int) error {
+	log := "modules.apikey.usecase.RevokeKey: %s"
+
+	ctx, cancel := context.WithTimeout(ctx, u.contextTimeout)
+	defer cancel()
+
+	key, err := u.ownedKey(ctx, caller, id)
+	if err != nil {
+		u.log.Error(log, err.Error())
+
+		return err
+	}
+
+	// Revoking twice keeps the original revocation time
+	if key.RevokedAt != nil {
+		return nil
+	}
+
+	if err := u.apiKeyRepo.UpdateAPIKey(ctx, id, map[string]interface{}{"revoked_at": time.Now()}); err != nil {
+		u.log.Error(log, err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+func (u *APIKeyUsecase) Authenticate(ctx context.Context, plain string) (*auth.User, error) {
+	log := "modules.apikey.usecase.Authenticate: %s"
+
+	ctx, cancel := context.WithTimeout(ctx, u.contextTimeout)
+	defer cancel()
+
+	key, err := u.apiKeyRepo.GetAPIKeyByHash(ctx, hashKey(plain))
+	if err == errors.ErrNotFound {
+		return nil, errors.AuthError("Invalid API key", nil)
+	}
+	if err != nil {
+		u.log.Error(log, err.Error())
+
+		return nil, errors.InternalServerError("Failed to verify API key", err)
+	}
+
+	now := time.Now()
+	if !key.Active(now) {
+		return nil, errors.AuthError("API key is expired or revoked", nil)
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > lastUsedInterval {
+		// Failing to record last_used_at must not fail the request
+		if err := u.apiKeyRepo.UpdateAPIKey(ctx, key.ID, map[string]interface{}{"last_used_at": now}); err != nil {
+			u.log.Error(log, err.Error())
+		}
+	}
+
+	return &auth.User{
+		ID:       fmt.Sprintf("%s:%d", apikey.ProviderName, key.ID),
+		Provider: apikey.ProviderName,
+		Name:     key.Name,
+		LocalID:  key.OwnerID,
+		Scopes:   key.ScopeList(),
+		APIKeyID: key.ID,
+	}, nil
+}
+
+// authorizeKey checks that caller may create a key for the owner with the requested scopes.
+// A key never carries more than its creator holds, and "*" cannot be granted to a key at all.
+func (u *APIKeyUsecase) authorizeKey(ctx context.Context, caller *auth.User, request *entity.APIKeyRequest) error {
+	log := "modules.apikey.usecase.authorizeKey: %s"
+
+	for _, scope := range request.Scopes {
+		if scope == "*" {
+			return errors.FieldErr("scopes", errors.ErrInvalidValue)
+		}
+	}
+
+	if request.OwnerID != caller.LocalID {
+		admin, err := u.isAdmin(ctx, caller)
+		if err != nil {
+			return err
+		}
+		if !admin {
+			return errors.ForbiddenError("You cannot create API keys for other users", nil)
+		}
+	}
+
+	granted, err := u.rbacUsecase.GetPermissions(ctx, caller)
+	if err != nil {
+		u.log.Error(log, err.Error())
+
+		return errors.InternalServerError("Failed to check permissions", err)
+	}
+	for _, scope := range request.Scopes {
+		if !rbac.MatchPermission(granted, scope) {
+			return errors.ForbiddenError(fmt.Sprintf("You cannot grant the scope %s", scope), nil)
+		}
+	}
+
+	return nil
+}
+
+// ownedKey returns the key with id when caller owns it or has AdminPermission. Keys of other
+// owners are reported as not found so their IDs cannot be probed.
+func (u *APIKeyUsecase) ownedKey(ctx context.Context, caller *auth.User, id int) (*entity.APIKey, error) {
+	key, err := u.apiKeyRepo.GetAPIKeyByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if caller.LocalID != 0 && key.OwnerID == caller.LocalID {
+		return key, nil
+	}
+
+	admin, err := u.isAdmin(ctx, caller)
+	if err != nil {
+		return nil, err
+	}
+	if !admin {
+		return nil, errors.ErrNotFound
+	}
+
+	return key, nil
+}
+
+// isAdmin reports whether caller may manage API keys of other users
+func (u *APIKeyUsecase) isAdmin(ctx context.Context, caller *auth.User) (bool, error) {
+	log := "modules.apikey.usecase.isAdmin: %s"
+
+	admin, err := u.rbacUsecase.HasPermission(ctx, caller, apikey.AdminPermission)
+	if err != nil {
+		u.log.Error(log, err.Error())
+
+		return false, errors.InternalServerError("Failed to check permissions", err)
+	}
+
+	return admin, nil
+}
+
+// generateKey returns a new random key with the ak_ prefix
+func generateKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return keyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashKey returns the SHA-256 of the key; keys are random, so a slow hash adds nothing
+func hashKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
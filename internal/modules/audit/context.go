@@ -0,0 +1,22 @@
+package audit
+
+import "context"
+
+type clientKey struct{}
+
+// Client identifies where a request came from
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// WithClient returns a copy of ctx carrying the request client
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// ClientFromContext returns the client stored with WithClient, empty when there is none
+func ClientFromContext(ctx context.Context) Client {
+	client, _ := ctx.Value(clientKey{}).(Client)
+	return client
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"djiroutine-go-clean-architecture/internal/entity"
+	"djiroutine-go-clean-architecture/internal/modules/audit"
+	"djiroutine-go-clean-architecture/pkg"
+	"djiroutine-go-clean-architecture/pkg/helper"
+	"djiroutine-go-clean-architecture/pkg/logger"
+
+	"github.com/labstack/echo/v4"
+)
+
+type AuditHandler struct {
+	Log          logger.Logger
+	AuditUsecase audit.UseCase
+}
+
+func NewAuditHandler(log logger.Logger, auditUseCase audit.UseCase) *AuditHandler {
+	return &AuditHandler{
+		Log:          log,
+		AuditUsecase: auditUseCase,
+	}
+}
+
+func (h *AuditHandler) ListEvents(c echo.Context) error {
+	response := new(pkg.ResponseWithPaginator)
+
+	request, err := helper.Bind[entity.AuditRequestList](c)
+	if err != nil {
+		return err
+	}
+
+	_, _, offset := helper.Pagination(helper.IntToString(*request.Page), helper.IntToString(*request.Limit))
+	request.Offset = helper.IntToIntNullable(offset)
+
+	res, total, err := h.AuditUsecase.ListEvents(c.Request().Context(), request)
+	if err != nil {
+		return err
+	}
+
+	response.MappingResponseSuccess("Get audit events successfull", res)
+	response.MappingPagination(int32(*request.Page), int32(*request.Limit), int(total), len(res), response.Response)
+
+	return c.JSON(response.Code, response)
+}
@@ -0,0 +1,11 @@
+package audit
+
+import (
+	"context"
+	"djiroutine-go-clean-architecture/internal/entity"
+)
+
+type Repository interface {
+	CreateEvent(ctx context.Context, event *entity.AuditEvent) error
+	ListEvents(ctx context.Context, param *entity.AuditRequestList) ([]*entity.AuditEvent, int64, error)
+}
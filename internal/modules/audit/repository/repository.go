@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"djiroutine-go-clean-architecture/internal/entity"
+	"djiroutine-go-clean-architecture/pkg/config"
+	"djiroutine-go-clean-architecture/pkg/errors"
+	"djiroutine-go-clean-architecture/pkg/helper"
+	"djiroutine-go-clean-architecture/pkg/logger"
+	"djiroutine-go-clean-architecture/pkg/query"
+)
+
+type AuditRepository struct {
+	db  config.DBService
+	log logger.Logger
+}
+
+func NewAuditRepository(db config.DBService, log logger.Logger) *AuditRepository {
+	return &AuditRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *AuditRepository) CreateEvent(ctx context.Context, event *entity.AuditEvent) error {
+	log := "modules.audit.repository.CreateEvent: %s"
+
+	err := r.db.GetConnection().WithContext(ctx).Create(event).Error
+	if err != nil {
+		r.log.Error(log, err)
+
+		return errors.ErrInternalServerError
+	}
+
+	return nil
+}
+
+// ListEvents returns the page of events matching param, newest first, and their total
+func (r *AuditRepository) ListEvents(ctx context.Context, param *entity.AuditRequestList) ([]*entity.AuditEvent, int64, error) {
+	log := "modules.audit.repository.ListEvents: %s"
+
+	from, to, err := param.ParseRange()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	spec := query.Spec{
+		Filters: []query.Filter{
+			query.Equal("event_type", param.EventType),
+			query.Equal("subject", param.Subject),
+			query.EqualFold("email", param.Email),
+			query.Equal("actor", param.Actor),
+			query.Equal("outcome", param.Outcome),
+			query.Gte("created_at", from),
+			query.Lt("created_at", to),
+		},
+		Sort:   []query.Sort{{Column: "created_at", Desc: true}, {Column: "id", Desc: true}},
+		Limit:  helper.IntNullableToInt(param.Limit),
+		Offset: helper.IntNullableToInt(param.Offset),
+	}
+
+	res, total, err := query.Find[entity.AuditEvent](r.db.GetConnection().WithContext(ctx).Model(&entity.AuditEvent{}), spec)
+	if err != nil {
+		r.log.Error(log, err)
+
+		return nil, 0, errors.ErrInternalServerError
+	}
+
+	return res, total, nil
+}
@@ -0,0 +1,17 @@
+package audit
+
+import (
+	"context"
+	"djiroutine-go-clean-architecture/internal/entity"
+)
+
+type UseCase interface {
+	// Record queues the event for writing and never blocks the caller. IP and user agent
+	// are taken from ctx when the event does not set them.
+	Record(ctx context.Context, event *entity.AuditEvent)
+
+	ListEvents(ctx context.Context, request *entity.AuditRequestList) ([]*entity.AuditEvent, int64, error)
+
+	// Close stops accepting events and waits until the queued ones are written
+	Close()
+}
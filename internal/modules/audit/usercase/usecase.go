@@ -0,0 +1,116 @@
+package usecase
+
+import (
+	"context"
+	"djiroutine-go-clean-architecture/internal/entity"
+	"djiroutine-go-clean-architecture/internal/modules/audit"
+	"djiroutine-go-clean-architecture/pkg/logger"
+	"sync"
+	"time"
+)
+
+// DefaultBufferSize is how many events can wait for the writer before new ones are dropped
+const DefaultBufferSize = 1024
+
+type AuditUsecase struct {
+	auditRepo      audit.Repository
+	contextTimeout time.Duration
+	log            logger.Logger
+
+	events chan *entity.AuditEvent
+	done   chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewAuditUsecase starts the background writer; call Close on shutdown to flush queued events
+func NewAuditUsecase(auditRepo audit.Repository, timeout time.Duration, bufferSize int, log logger.Logger) audit.UseCase {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+
+	u := &AuditUsecase{
+		auditRepo:      auditRepo,
+		contextTimeout: timeout,
+		log:            log,
+		events:         make(chan *entity.AuditEvent, bufferSize),
+		done:           make(chan struct{}),
+	}
+	go u.writer()
+
+	return u
+}
+
+func (u *AuditUsecase) Record(ctx context.Context, event *entity.AuditEvent) {
+	log := "modules.audit.usecase.Record: %s"
+
+	client := audit.ClientFromContext(ctx)
+	if event.IP == "" {
+		event.IP = client.IP
+	}
+	if event.UserAgent == "" {
+		event.UserAgent = client.UserAgent
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	if u.closed {
+		return
+	}
+
+	// Auditing must never slow down or fail a login, so a full queue drops the event
+	select {
+	case u.events <- event:
+	default:
+		u.log.Warn(log, "queue is full, dropping "+event.EventType+" event for "+event.Subject)
+	}
+}
+
+func (u *AuditUsecase) ListEvents(ctx context.Context, request *entity.AuditRequestList) (res []*entity.AuditEvent, total int64, err error) {
+	log := "modules.audit.usecase.ListEvents: %s"
+
+	ctx, cancel := context.WithTimeout(ctx, u.contextTimeout)
+	defer cancel()
+
+	res, total, err = u.auditRepo.ListEvents(ctx, request)
+	if err != nil {
+		u.log.Error(log, err.Error())
+
+		return nil, 0, err
+	}
+
+	return res, total, nil
+}
+
+func (u *AuditUsecase) Close() {
+	u.mu.Lock()
+	if u.closed {
+		u.mu.Unlock()
+		return
+	}
+	u.closed = true
+	close(u.events)
+	u.mu.Unlock()
+
+	<-u.done
+}
+
+// writer persists queued events until the queue is closed. Events are written with
+// their own context because the request that produced them has usually finished.
+func (u *AuditUsecase) writer() {
+	log := "modules.audit.usecase.writer: %s"
+	defer close(u.done)
+
+	for event := range u.events {
+		ctx, cancel := context.WithTimeout(context.Background(), u.contextTimeout)
+		if err := u.auditRepo.CreateEvent(ctx, event); err != nil {
+			u.log.Error(log, err.Error())
+		}
+		cancel()
+	}
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"djiroutine-go-clean-architecture/internal/modules/auth"
 	"djiroutine-go-clean-architecture/pkg/errors"
+	"djiroutine-go-clean-architecture/pkg/helper"
 	"net/http"
 	"strings"
 
@@ -19,20 +20,32 @@ func NewAuthHandler(authUseCase auth.UseCase) *AuthHandler {
 	}
 }
 
-// Login mengarahkan pengguna ke halaman login OAuth
+// Providers menampilkan daftar provider login yang tersedia
+func (h *AuthHandler) Providers(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"providers": h.authUseCase.Providers(),
+	})
+}
+
+// Login mengarahkan pengguna ke halaman login OAuth.
+// Tanpa parameter :provider, provider default yang dipakai.
 func (h *AuthHandler) Login(c echo.Context) error {
-	authURL, state, err := h.authUseCase.GetAuthorizationURL(c.Request().Context())
+	// scope opsional, dipisahkan spasi atau koma
+	scopes := strings.FieldsFunc(c.QueryParam("scope"), func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+
+	authURL, state, err := h.authUseCase.GetAuthorizationURL(c.Request().Context(), c.Param("provider"), c.QueryParam("redirect_to"), scopes)
 	if err != nil {
-		if appErr, ok := err.(*errors.AppError); ok {
-			return c.JSON(appErr.Code, map[string]string{
-				"error": appErr.Message,
-			})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to generate authorization URL",
-		})
+		return err
 	}
 
+	// Ikat state ke browser ini agar callback dari browser lain ditolak (login CSRF).
+	// SameSite selalu Lax: callback adalah redirect lintas situs dari SSO, cookie Strict tidak ikut terkirim.
+	stateConfig := h.authUseCase.StateConfig()
+	sessionConfig := h.authUseCase.SessionConfig()
+	helper.SetSecureHttpCookie(c, stateConfig.CookieName, auth.SignState(stateConfig.Secret, state), int(stateConfig.TTL.Seconds()), true, sessionConfig.Secure, http.SameSiteLaxMode)
+
 	return c.JSON(http.StatusOK, map[string]string{
 		"auth_url": authURL,
 		"state":    state,
@@ -45,25 +58,76 @@ func (h *AuthHandler) Callback(c echo.Context) error {
 	state := c.QueryParam("state")
 
 	if code == "" || state == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Missing code or state parameter",
-		})
+		return errors.BadRequestError("Missing code or state parameter", nil)
+	}
+
+	stateConfig := h.authUseCase.StateConfig()
+	stateCookie := ""
+	if cookie, err := c.Cookie(stateConfig.CookieName); err == nil {
+		stateCookie = cookie.Value
 	}
 
-	user, token, err := h.authUseCase.ProcessCallback(c.Request().Context(), code, state)
+	loginState, err := h.authUseCase.ConsumeState(c.Request().Context(), c.Param("provider"), state, stateCookie)
 	if err != nil {
-		if appErr, ok := err.(*errors.AppError); ok {
-			return c.JSON(appErr.Code, map[string]string{
-				"error": appErr.Message,
-			})
+		return err
+	}
+
+	// Cookie state hanya berlaku untuk satu kali callback
+	sessionConfig := h.authUseCase.SessionConfig()
+	helper.SetSecureHttpCookie(c, stateConfig.CookieName, "", -1, true, sessionConfig.Secure, http.SameSiteLaxMode)
+
+	user, token, err := h.authUseCase.ProcessCallback(c.Request().Context(), c.Param("provider"), code, state)
+	if err != nil {
+		return err
+	}
+
+	// Mode sesi: token disimpan di server, browser hanya menerima cookie
+	if sessionConfig.Enabled {
+		session, err := h.authUseCase.CreateSession(c.Request().Context(), user, token)
+		if err != nil {
+			return err
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Authentication failed",
+
+		setSessionCookies(c, sessionConfig, session.ID, session.CSRFToken, int(sessionConfig.TTL.Seconds()))
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"user":        user,
+			"csrf_token":  session.CSRFToken,
+			"redirect_to": loginState.RedirectTo,
 		})
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"user":  user,
+		"user":        user,
+		"token":       token,
+		"redirect_to": loginState.RedirectTo,
+	})
+}
+
+// setSessionCookies memasang cookie sesi (HttpOnly) dan cookie CSRF yang bisa dibaca JavaScript
+func setSessionCookies(c echo.Context, config auth.SessionConfig, sessionID, csrfToken string, age int) {
+	helper.SetSecureHttpCookie(c, config.CookieName, sessionID, age, true, config.Secure, config.SameSite)
+	helper.SetSecureHttpCookie(c, config.CSRFCookieName, csrfToken, age, false, config.Secure, config.SameSite)
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+	Provider     string `json:"provider"`
+}
+
+// Refresh menukar refresh token dengan token baru tanpa login ulang
+func (h *AuthHandler) Refresh(c echo.Context) error {
+	request, err := helper.Bind[refreshRequest](c)
+	if err != nil {
+		return err
+	}
+
+	token, err := h.authUseCase.RefreshToken(c.Request().Context(), request.Provider, request.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
 		"token": token,
 	})
 }
@@ -71,34 +135,131 @@ func (h *AuthHandler) Callback(c echo.Context) error {
 // Logout mengakhiri sesi pengguna
 func (h *AuthHandler) Logout(c echo.Context) error {
 	authHeader := c.Request().Header.Get("Authorization")
+
+	sessionConfig := h.authUseCase.SessionConfig()
+	if authHeader == "" && sessionConfig.Enabled {
+		if cookie, err := c.Cookie(sessionConfig.CookieName); err == nil && cookie.Value != "" {
+			return h.logoutSession(c, sessionConfig, cookie.Value)
+		}
+	}
+
 	if authHeader == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Authorization header is required",
-		})
+		return errors.BadRequestError("Authorization header is required", nil)
 	}
 
 	parts := strings.Split(authHeader, " ")
 	if len(parts) != 2 || parts[0] != "Bearer" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Authorization header format must be Bearer {token}",
-		})
+		return errors.BadRequestError("Authorization header format must be Bearer {token}", nil)
 	}
 
 	token := parts[1]
 
-	err := h.authUseCase.Logout(c.Request().Context(), token)
+	err := h.authUseCase.Logout(c.Request().Context(), c.Request().Header.Get(auth.ProviderHeader), token)
 	if err != nil {
-		if appErr, ok := err.(*errors.AppError); ok {
-			return c.JSON(appErr.Code, map[string]string{
-				"error": appErr.Message,
-			})
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Successfully logged out",
+	})
+}
+
+// logoutSession mengakhiri sesi berbasis cookie, wajib menyertakan header CSRF
+func (h *AuthHandler) logoutSession(c echo.Context, sessionConfig auth.SessionConfig, sessionID string) error {
+	session, err := h.authUseCase.ResolveSession(c.Request().Context(), sessionID)
+	if err == nil && !auth.ValidCSRFToken(session, c.Request().Header.Get(sessionConfig.CSRFHeaderName)) {
+		return errors.ForbiddenError("Invalid or missing CSRF token", nil)
+	}
+
+	if err == nil {
+		err = h.authUseCase.LogoutSession(c.Request().Context(), sessionID)
+	}
+
+	// Cookie tetap dihapus walaupun sesi sudah tidak ada di server
+	setSessionCookies(c, sessionConfig, "", "", -1)
+
+	if err != nil {
+		// Sesi yang sudah tidak valid tetap dianggap berhasil logout
+		if helper.GetStatusCode(err) != http.StatusUnauthorized {
+			return err
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Logout failed",
-		})
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{
 		"message": "Successfully logged out",
 	})
 }
+
+// LogoutAll mencabut semua token dan sesi milik pengguna yang sedang login (logout di semua perangkat)
+func (h *AuthHandler) LogoutAll(c echo.Context) error {
+	user, ok := c.Get("user").(*auth.User)
+	if !ok {
+		return errors.AuthError("Unauthorized", nil)
+	}
+
+	if user.APIKeyID != 0 {
+		return errors.BadRequestError("API keys have no sessions, revoke the key instead", nil)
+	}
+
+	if user.Actor != nil {
+		return errors.BadRequestError("Impersonated sessions cannot log the user out, end the impersonation instead", nil)
+	}
+
+	if err := h.authUseCase.LogoutAll(c.Request().Context(), nil, user.Provider, user.ID); err != nil {
+		return err
+	}
+
+	// Cookie sesi browser ini ikut dihapus
+	sessionConfig := h.authUseCase.SessionConfig()
+	if sessionConfig.Enabled {
+		setSessionCookies(c, sessionConfig, "", "", -1)
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Successfully logged out from all sessions",
+	})
+}
+
+// ForceLogout mencabut semua token dan sesi milik user lain berdasarkan sub (khusus admin)
+func (h *AuthHandler) ForceLogout(c echo.Context) error {
+	admin, ok := c.Get("user").(*auth.User)
+	if !ok {
+		return errors.AuthError("Unauthorized", nil)
+	}
+
+	if err := h.authUseCase.LogoutAll(c.Request().Context(), admin, c.QueryParam("provider"), c.Param("sub")); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "User has been logged out from all sessions",
+	})
+}
+
+// Impersonate menerbitkan token singkat agar admin bisa bertindak sebagai user lain (khusus admin).
+// Token dipakai sebagai "Authorization: Bearer {token}" dan diakhiri lewat /auth/logout.
+func (h *AuthHandler) Impersonate(c echo.Context) error {
+	actor, ok := c.Get("user").(*auth.User)
+	if !ok {
+		return errors.AuthError("Unauthorized", nil)
+	}
+
+	request, err := helper.Bind[auth.ImpersonationRequest](c)
+	if err != nil {
+		return err
+	}
+
+	impersonation, err := h.authUseCase.Impersonate(c.Request().Context(), actor, request)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"impersonation": impersonation,
+	})
+}
+
+// CacheStats menampilkan jumlah hit/miss cache validasi token
+func (h *AuthHandler) CacheStats(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.authUseCase.CacheStats())
+}
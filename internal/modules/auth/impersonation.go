@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	// ImpersonationTokenPrefix menandai token impersonasi agar bisa dibedakan dari token SSO
+	ImpersonationTokenPrefix = "imp_"
+
+	// ImpersonationProvider adalah User.Provider untuk user yang sedang di-impersonasi
+	ImpersonationProvider = "impersonation"
+
+	// ImpersonatePermission adalah permission yang dibutuhkan untuk memulai impersonasi
+	ImpersonatePermission = "users:impersonate"
+)
+
+// ImpersonationConfig adalah konfigurasi masa berlaku token impersonasi
+type ImpersonationConfig struct {
+	// TTL dipakai jika request tidak menentukan masa berlaku
+	TTL time.Duration
+	// MaxTTL adalah batas atas masa berlaku yang boleh diminta
+	MaxTTL time.Duration
+}
+
+// DefaultImpersonationConfig adalah konfigurasi impersonasi bawaan
+var DefaultImpersonationConfig = ImpersonationConfig{
+	TTL:    15 * time.Minute,
+	MaxTTL: time.Hour,
+}
+
+// ImpersonationRequest adalah permintaan admin untuk bertindak sebagai user lain
+type ImpersonationRequest struct {
+	// UserID adalah ID user lokal (auth_user) yang akan di-impersonasi
+	UserID int `json:"user_id" validate:"required,min=1"`
+	// Reason wajib diisi, misalnya nomor tiket, dan dicatat di audit log
+	Reason string `json:"reason" validate:"required"`
+	// TTL dalam detik, kosong berarti ImpersonationConfig.TTL
+	TTL int `json:"ttl" validate:"min=0"`
+	// Scopes membatasi permission selama impersonasi, kosong berarti semua permission user target
+	Scopes []string `json:"scopes"`
+}
+
+// Impersonation adalah sesi singkat di mana Actor bertindak sebagai User
+type Impersonation struct {
+	Token     string    `json:"token,omitempty"`
+	User      *User     `json:"user"`
+	Actor     *User     `json:"actor"`
+	Reason    string    `json:"reason"`
+	Scopes    []string  `json:"scopes,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// IsImpersonationToken mengembalikan true jika token diterbitkan oleh fitur impersonasi
+func IsImpersonationToken(token string) bool {
+	return strings.HasPrefix(token, ImpersonationTokenPrefix)
+}
+
+// ActorID mengembalikan admin yang bertindak atas user, yaitu yang meng-impersonasi atau
+// memaksa logout, dalam format "provider:sub", kosong jika tidak ada
+func (u *User) ActorID() string {
+	if u == nil || u.Actor == nil {
+		return ""
+	}
+	return u.Actor.Provider + ":" + u.Actor.ID
+}
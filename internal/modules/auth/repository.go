@@ -1,3 +1,67 @@
 package auth
 
-type AuthRepository interface{}
+import (
+	"context"
+	"time"
+)
+
+// AuthRepository menyimpan data autentikasi sementara (cache token dan sesi) di store.Store
+type AuthRepository interface {
+	// GetCachedUser mengembalikan user dari cache, nil jika tidak ditemukan
+	GetCachedUser(ctx context.Context, tokenHash string) (*User, error)
+
+	// CacheUser menyimpan user hasil validasi token selama ttl
+	CacheUser(ctx context.Context, tokenHash string, user *User, ttl time.Duration) error
+
+	// DeleteCachedUser menghapus user dari cache
+	DeleteCachedUser(ctx context.Context, tokenHash string) error
+
+	// GetSession mengembalikan sesi berdasarkan ID, nil jika tidak ditemukan
+	GetSession(ctx context.Context, sessionID string) (*Session, error)
+
+	// SaveSession menyimpan sesi selama ttl
+	SaveSession(ctx context.Context, session *Session, ttl time.Duration) error
+
+	// DeleteSession menghapus sesi
+	DeleteSession(ctx context.Context, sessionID string) error
+
+	// SaveLoginState menyimpan state login selama ttl
+	SaveLoginState(ctx context.Context, state *LoginState, ttl time.Duration) error
+
+	// DenyToken memasukkan token ke denylist selama ttl
+	DenyToken(ctx context.Context, tokenHash string, ttl time.Duration) error
+
+	// IsTokenDenied mengembalikan true jika token ada di denylist
+	IsTokenDenied(ctx context.Context, tokenHash string) (bool, error)
+
+	// SaveTokenIssuedAt menyimpan waktu terbit token (iat, atau waktu pertama kali terlihat untuk token opaque)
+	SaveTokenIssuedAt(ctx context.Context, tokenHash string, issuedAt time.Time, ttl time.Duration) error
+
+	// GetTokenIssuedAt mengembalikan waktu terbit token, zero jika belum tercatat
+	GetTokenIssuedAt(ctx context.Context, tokenHash string) (time.Time, error)
+
+	// SaveTokenExpiry menyimpan waktu kedaluwarsa access token opaque yang diterbitkan lewat callback atau refresh
+	SaveTokenExpiry(ctx context.Context, tokenHash string, expiresAt time.Time, ttl time.Duration) error
+
+	// GetTokenExpiry mengembalikan waktu kedaluwarsa token opaque, zero jika tidak diketahui
+	GetTokenExpiry(ctx context.Context, tokenHash string) (time.Time, error)
+
+	// SetRevokedBefore mencabut semua token dan sesi user yang terbit sebelum atau pada waktu t
+	SetRevokedBefore(ctx context.Context, userKey string, t time.Time, ttl time.Duration) error
+
+	// GetRevokedBefore mengembalikan batas waktu logout-all user, zero jika tidak ada
+	GetRevokedBefore(ctx context.Context, userKey string) (time.Time, error)
+
+	// SaveImpersonation menyimpan sesi impersonasi selama ttl
+	SaveImpersonation(ctx context.Context, tokenHash string, impersonation *Impersonation, ttl time.Duration) error
+
+	// GetImpersonation mengembalikan sesi impersonasi, nil jika tidak ditemukan atau kedaluwarsa
+	GetImpersonation(ctx context.Context, tokenHash string) (*Impersonation, error)
+
+	// DeleteImpersonation menghapus sesi impersonasi
+	DeleteImpersonation(ctx context.Context, tokenHash string) error
+
+	// ConsumeLoginState mengambil dan menghapus state secara atomik (sekali pakai).
+	// used bernilai true jika state sudah pernah dipakai sebelumnya dalam ttl.
+	ConsumeLoginState(ctx context.Context, state string, ttl time.Duration) (loginState *LoginState, used bool, err error)
+}
@@ -1,7 +1,355 @@
 package repository
 
-type AuthRepository struct{}
+import (
+	"context"
+	"djiroutine-go-clean-architecture/internal/modules/auth"
+	"djiroutine-go-clean-architecture/pkg/logger"
+	"djiroutine-go-clean-architecture/pkg/store"
+	"encoding/json"
+	"strconv"
+	"time"
+)
 
-func NewAuthRepository() AuthRepository {
-	return AuthRepository{}
+const (
+	tokenCachePrefix    = "auth_token_cache_"
+	sessionPrefix       = "auth_session_"
+	statePrefix         = "auth_state_"
+	stateUsedPrefix     = "auth_state_used_"
+	deniedPrefix        = "auth_token_denied_"
+	issuedAtPrefix      = "auth_token_issued_at_"
+	expiresAtPrefix     = "auth_token_expires_at_"
+	revokedPrefix       = "auth_revoked_before_"
+	impersonationPrefix = "auth_impersonation_"
+)
+
+type AuthRepository struct {
+	store store.Store
+	cache store.Store
+	log   logger.Logger
+}
+
+// NewAuthRepository menyimpan state, sesi dan denylist di store, sedangkan cache validasi
+// token di cache. Cache ditulis pada setiap cache miss, jadi sebaiknya memory atau redis.
+func NewAuthRepository(store, cache store.Store, log logger.Logger) auth.AuthRepository {
+	return &AuthRepository{
+		store: store,
+		cache: cache,
+		log:   log,
+	}
+}
+
+func (r *AuthRepository) GetCachedUser(ctx context.Context, tokenHash string) (*auth.User, error) {
+	log := "modules.auth.repository.GetCachedUser: %s"
+
+	data, err := r.cache.Get(ctx, tokenCachePrefix+tokenHash)
+	if err == store.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		r.log.Error(log, err)
+
+		return nil, err
+	}
+
+	user := new(auth.User)
+	if err := json.Unmarshal([]byte(data), user); err != nil {
+		r.log.Error(log, err)
+
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (r *AuthRepository) CacheUser(ctx context.Context, tokenHash string, user *auth.User, ttl time.Duration) error {
+	log := "modules.auth.repository.CacheUser: %s"
+
+	data, err := json.Marshal(user)
+	if err != nil {
+		r.log.Error(log, err)
+
+		return err
+	}
+
+	if err := r.cache.Set(ctx, tokenCachePrefix+tokenHash, string(data), ttl); err != nil {
+		r.log.Error(log, err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) DeleteCachedUser(ctx context.Context, tokenHash string) error {
+	log := "modules.auth.repository.DeleteCachedUser: %s"
+
+	if err := r.cache.Delete(ctx, tokenCachePrefix+tokenHash); err != nil {
+		r.log.Error(log, err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) GetSession(ctx context.Context, sessionID string) (*auth.Session, error) {
+	log := "modules.auth.repository.GetSession: %s"
+
+	data, err := r.store.Get(ctx, sessionPrefix+sessionID)
+	if err == store.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		r.log.Error(log, err)
+
+		return nil, err
+	}
+
+	session := new(auth.Session)
+	if err := json.Unmarshal([]byte(data), session); err != nil {
+		r.log.Error(log, err)
+
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (r *AuthRepository) SaveSession(ctx context.Context, session *auth.Session, ttl time.Duration) error {
+	log := "modules.auth.repository.SaveSession: %s"
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		r.log.Error(log, err)
+
+		return err
+	}
+
+	if err := r.store.Set(ctx, sessionPrefix+session.ID, string(data), ttl); err != nil {
+		r.log.Error(log, err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) DeleteSession(ctx context.Context, sessionID string) error {
+	log := "modules.auth.repository.DeleteSession: %s"
+
+	if err := r.store.Delete(ctx, sessionPrefix+sessionID); err != nil {
+		r.log.Error(log, err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) SaveLoginState(ctx context.Context, state *auth.LoginState, ttl time.Duration) error {
+	log := "modules.auth.repository.SaveLoginState: %s"
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		r.log.Error(log, err)
+
+		return err
+	}
+
+	if err := r.store.Set(ctx, statePrefix+state.State, string(data), ttl); err != nil {
+		r.log.Error(log, err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) ConsumeLoginState(ctx context.Context, state string, ttl time.Duration) (*auth.LoginState, bool, error) {
+	log := "modules.auth.repository.ConsumeLoginState: %s"
+
+	data, err := r.store.Take(ctx, statePrefix+state)
+	if err == store.ErrNotFound {
+		_, err := r.store.Get(ctx, stateUsedPrefix+state)
+		if err == store.ErrNotFound {
+			return nil, false, nil
+		}
+		if err != nil {
+			r.log.Error(log, err)
+
+			return nil, false, err
+		}
+		return nil, true, nil
+	}
+	if err != nil {
+		r.log.Error(log, err)
+
+		return nil, false, err
+	}
+
+	// Tandai state sudah dipakai agar replay bisa dibedakan dari state kedaluwarsa
+	if err := r.store.Set(ctx, stateUsedPrefix+state, "1", ttl); err != nil {
+		r.log.Error(log, err)
+	}
+
+	loginState := new(auth.LoginState)
+	if err := json.Unmarshal([]byte(data), loginState); err != nil {
+		r.log.Error(log, err)
+
+		return nil, false, err
+	}
+
+	return loginState, false, nil
+}
+
+func (r *AuthRepository) DenyToken(ctx context.Context, tokenHash string, ttl time.Duration) error {
+	log := "modules.auth.repository.DenyToken: %s"
+
+	if err := r.store.Set(ctx, deniedPrefix+tokenHash, "1", ttl); err != nil {
+		r.log.Error(log, err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) IsTokenDenied(ctx context.Context, tokenHash string) (bool, error) {
+	log := "modules.auth.repository.IsTokenDenied: %s"
+
+	_, err := r.store.Get(ctx, deniedPrefix+tokenHash)
+	if err == store.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		r.log.Error(log, err)
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *AuthRepository) SaveTokenIssuedAt(ctx context.Context, tokenHash string, issuedAt time.Time, ttl time.Duration) error {
+	log := "modules.auth.repository.SaveTokenIssuedAt: %s"
+
+	if err := r.store.Set(ctx, issuedAtPrefix+tokenHash, strconv.FormatInt(issuedAt.Unix(), 10), ttl); err != nil {
+		r.log.Error(log, err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) GetTokenIssuedAt(ctx context.Context, tokenHash string) (time.Time, error) {
+	return r.getUnixTime(ctx, "modules.auth.repository.GetTokenIssuedAt: %s", issuedAtPrefix+tokenHash)
+}
+
+func (r *AuthRepository) SaveTokenExpiry(ctx context.Context, tokenHash string, expiresAt time.Time, ttl time.Duration) error {
+	log := "modules.auth.repository.SaveTokenExpiry: %s"
+
+	if err := r.store.Set(ctx, expiresAtPrefix+tokenHash, strconv.FormatInt(expiresAt.Unix(), 10), ttl); err != nil {
+		r.log.Error(log, err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) GetTokenExpiry(ctx context.Context, tokenHash string) (time.Time, error) {
+	return r.getUnixTime(ctx, "modules.auth.repository.GetTokenExpiry: %s", expiresAtPrefix+tokenHash)
+}
+
+func (r *AuthRepository) SetRevokedBefore(ctx context.Context, userKey string, t time.Time, ttl time.Duration) error {
+	log := "modules.auth.repository.SetRevokedBefore: %s"
+
+	if err := r.store.Set(ctx, revokedPrefix+userKey, strconv.FormatInt(t.Unix(), 10), ttl); err != nil {
+		r.log.Error(log, err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) GetRevokedBefore(ctx context.Context, userKey string) (time.Time, error) {
+	return r.getUnixTime(ctx, "modules.auth.repository.GetRevokedBefore: %s", revokedPrefix+userKey)
+}
+
+func (r *AuthRepository) SaveImpersonation(ctx context.Context, tokenHash string, impersonation *auth.Impersonation, ttl time.Duration) error {
+	log := "modules.auth.repository.SaveImpersonation: %s"
+
+	data, err := json.Marshal(impersonation)
+	if err != nil {
+		r.log.Error(log, err)
+
+		return err
+	}
+
+	if err := r.store.Set(ctx, impersonationPrefix+tokenHash, string(data), ttl); err != nil {
+		r.log.Error(log, err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) GetImpersonation(ctx context.Context, tokenHash string) (*auth.Impersonation, error) {
+	log := "modules.auth.repository.GetImpersonation: %s"
+
+	data, err := r.store.Get(ctx, impersonationPrefix+tokenHash)
+	if err == store.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		r.log.Error(log, err)
+
+		return nil, err
+	}
+
+	impersonation := new(auth.Impersonation)
+	if err := json.Unmarshal([]byte(data), impersonation); err != nil {
+		r.log.Error(log, err)
+
+		return nil, err
+	}
+
+	return impersonation, nil
+}
+
+func (r *AuthRepository) DeleteImpersonation(ctx context.Context, tokenHash string) error {
+	log := "modules.auth.repository.DeleteImpersonation: %s"
+
+	if err := r.store.Delete(ctx, impersonationPrefix+tokenHash); err != nil {
+		r.log.Error(log, err)
+
+		return err
+	}
+
+	return nil
+}
+
+// getUnixTime membaca waktu yang disimpan sebagai detik unix, zero jika key tidak ada
+func (r *AuthRepository) getUnixTime(ctx context.Context, log, key string) (time.Time, error) {
+	data, err := r.store.Get(ctx, key)
+	if err == store.ErrNotFound {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		r.log.Error(log, err)
+
+		return time.Time{}, err
+	}
+
+	seconds, err := strconv.ParseInt(data, 10, 64)
+	if err != nil {
+		r.log.Error(log, err)
+
+		return time.Time{}, err
+	}
+
+	return time.Unix(seconds, 0), nil
 }
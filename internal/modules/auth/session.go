@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"time"
+)
+
+// Config adalah konfigurasi auth use case
+type Config struct {
+	// CacheTTL adalah batas atas lama user hasil validasi token disimpan di cache
+	CacheTTL time.Duration
+
+	// OpaqueCacheTTL membatasi lama cache token opaque yang waktu kedaluwarsanya tidak diketahui,
+	// yaitu token yang tidak diterbitkan lewat callback atau refresh aplikasi ini
+	OpaqueCacheTTL time.Duration
+
+	// RevocationTTL adalah lama token yang dicabut dan logout-all disimpan di denylist.
+	// Nilainya harus lebih panjang dari masa berlaku access token di SSO.
+	RevocationTTL time.Duration
+
+	Session       SessionConfig
+	State         StateConfig
+	Impersonation ImpersonationConfig
+}
+
+// DefaultRevocationTTL dipakai jika Config.RevocationTTL kosong
+const DefaultRevocationTTL = 24 * time.Hour
+
+// DefaultOpaqueCacheTTL dipakai jika Config.OpaqueCacheTTL kosong
+const DefaultOpaqueCacheTTL = time.Minute
+
+// CacheStatsPermission dibutuhkan untuk melihat statistik cache validasi token
+const CacheStatsPermission = "auth:stats"
+
+// SessionConfig adalah konfigurasi mode sesi berbasis cookie untuk klien browser
+type SessionConfig struct {
+	Enabled        bool
+	CookieName     string
+	CSRFCookieName string
+	CSRFHeaderName string
+	Secure         bool
+	SameSite       http.SameSite
+	TTL            time.Duration
+}
+
+// DefaultSessionConfig adalah konfigurasi sesi bawaan
+var DefaultSessionConfig = SessionConfig{
+	CookieName:     "session_id",
+	CSRFCookieName: "csrf_token",
+	CSRFHeaderName: "X-CSRF-Token",
+	Secure:         true,
+	SameSite:       http.SameSiteLaxMode,
+	TTL:            24 * time.Hour,
+}
+
+// Session adalah sesi login yang disimpan di server, token tidak pernah dikirim ke browser
+type Session struct {
+	ID        string    `json:"id"`
+	User      *User     `json:"user"`
+	Token     *Token    `json:"token"`
+	CSRFToken string    `json:"csrf_token"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ValidCSRFToken membandingkan token CSRF dari header dengan milik sesi secara constant-time
+func ValidCSRFToken(session *Session, headerToken string) bool {
+	if session == nil || headerToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(session.CSRFToken), []byte(headerToken)) == 1
+}
+
+// IsSafeMethod mengembalikan true untuk method HTTP yang tidak mengubah data dan tidak butuh CSRF
+func IsSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
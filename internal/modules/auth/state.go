@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// StateConfig adalah konfigurasi validasi parameter state OAuth
+type StateConfig struct {
+	// Secret adalah kunci HMAC untuk menandatangani cookie state
+	Secret     []byte
+	CookieName string
+	TTL        time.Duration
+
+	// RedirectAllowList berisi origin (scheme://host) yang boleh menjadi tujuan redirect_to;
+	// path relatif seperti "/dashboard" selalu diizinkan
+	RedirectAllowList []string
+}
+
+// DefaultStateConfig adalah konfigurasi state bawaan
+var DefaultStateConfig = StateConfig{
+	CookieName: "oauth_state",
+	TTL:        10 * time.Minute,
+}
+
+// LoginState adalah data login yang disimpan di server sampai callback diterima
+type LoginState struct {
+	State      string    `json:"state"`
+	Provider   string    `json:"provider"`
+	RedirectTo string    `json:"redirect_to,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// SignState menghasilkan nilai cookie yang mengikat state ke browser yang memulai login
+func SignState(secret []byte, state string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(state))
+	return state + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyStateCookie memastikan cookie ditandatangani server dan berisi state yang sama
+func VerifyStateCookie(secret []byte, cookieValue, state string) bool {
+	i := strings.LastIndex(cookieValue, ".")
+	if i == -1 || cookieValue[:i] != state {
+		return false
+	}
+	return hmac.Equal([]byte(cookieValue), []byte(SignState(secret, state)))
+}
+
+// ValidRedirect memeriksa redirect_to terhadap allow-list untuk mencegah open redirect
+func ValidRedirect(redirectTo string, allowList []string) bool {
+	if redirectTo == "" {
+		return true
+	}
+
+	// Path relatif diizinkan, kecuali "//host" dan "/\\host" yang dianggap absolut oleh browser
+	if strings.HasPrefix(redirectTo, "/") {
+		return !strings.HasPrefix(redirectTo, "//") && !strings.HasPrefix(redirectTo, "/\\")
+	}
+
+	target, err := url.Parse(redirectTo)
+	if err != nil || target.Host == "" || (target.Scheme != "https" && target.Scheme != "http") {
+		return false
+	}
+
+	origin := target.Scheme + "://" + target.Host
+	for _, allowed := range allowList {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+
+	return false
+}
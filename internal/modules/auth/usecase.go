@@ -2,27 +2,113 @@ package auth
 
 import (
 	"context"
+	"time"
 )
 
+// ProviderHeader memilih provider yang memverifikasi bearer token. Wajib untuk token opaque jika
+// ada lebih dari satu provider, karena token tidak boleh dikirim ke provider yang tidak menerbitkannya.
+const ProviderHeader = "X-Auth-Provider"
+
 // User adalah tipe data yang mewakili informasi pengguna yang diautentikasi
 type User struct {
-	ID      string `json:"id"`
-	Email   string `json:"email"`
-	Name    string `json:"name"`
-	Profile string `json:"profile"`
+	ID       string `json:"id"`
+	Provider string `json:"provider"`
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Profile  string `json:"profile"`
+
+	// EmailVerified bernilai true jika provider menyatakan email sudah diverifikasi (email_verified)
+	EmailVerified bool `json:"email_verified"`
+
+	// LocalID adalah ID baris auth_user yang terhubung dengan akun SSO ini
+	LocalID int `json:"local_id"`
+
+	// Scopes adalah scope dari token bertanda tangan (JWT), kosong untuk token opaque
+	Scopes []string `json:"scopes,omitempty"`
+
+	// APIKeyID diisi jika user diautentikasi dengan API key; izinnya terbatas pada Scopes
+	APIKeyID int `json:"api_key_id,omitempty"`
+
+	// Actor adalah admin yang sebenarnya mengirim request saat user ini sedang di-impersonasi
+	Actor *User `json:"actor,omitempty"`
+
+	// ImpersonationScopes membatasi permission selama impersonasi, kosong berarti tidak dibatasi
+	ImpersonationScopes []string `json:"impersonation_scopes,omitempty"`
+}
+
+// Token adalah kumpulan token hasil login atau refresh
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresIn    int       `json:"expires_in"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	Scope        string    `json:"scope,omitempty"`
+
+	// RefreshTokenRotated bernilai true jika provider menerbitkan refresh token baru;
+	// klien wajib mengganti refresh token lama yang tidak berlaku lagi
+	RefreshTokenRotated bool `json:"refresh_token_rotated"`
+}
+
+// CacheStats adalah jumlah hit/miss cache validasi token sejak aplikasi berjalan
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
 }
 
 // UseCase adalah interface untuk use case autentikasi
 type UseCase interface {
-	// ValidateToken memvalidasi token dan mengembalikan informasi pengguna
-	ValidateToken(ctx context.Context, token string) (*User, error)
+	// ValidateToken memvalidasi token dan mengembalikan informasi pengguna. provider kosong berarti
+	// provider ditentukan dari iss token (JWT) atau provider satu-satunya.
+	ValidateToken(ctx context.Context, provider, token string) (*User, error)
+
+	// Providers mengembalikan nama provider login yang tersedia
+	Providers() []string
 
-	// GetAuthorizationURL menghasilkan URL otorisasi untuk login
-	GetAuthorizationURL(ctx context.Context) (string, string, error)
+	// GetAuthorizationURL menghasilkan URL otorisasi untuk login dan menyimpan state beserta redirectTo.
+	// provider kosong berarti provider default; scopes kosong berarti memakai scope bawaan provider.
+	GetAuthorizationURL(ctx context.Context, provider, redirectTo string, scopes []string) (string, string, error)
+
+	// StateConfig mengembalikan konfigurasi validasi state
+	StateConfig() StateConfig
+
+	// ConsumeState memvalidasi state callback terhadap cookie browser dan menandainya sudah dipakai
+	ConsumeState(ctx context.Context, provider, state, stateCookie string) (*LoginState, error)
 
 	// ProcessCallback memproses callback dari OAuth provider
-	ProcessCallback(ctx context.Context, code, state string) (*User, string, error)
+	ProcessCallback(ctx context.Context, provider, code, state string) (*User, *Token, error)
+
+	// RefreshToken menukar refresh token dengan token baru
+	RefreshToken(ctx context.Context, provider, refreshToken string) (*Token, error)
+
+	// Logout mengeluarkan pengguna dari sistem; provider dipakai seperti pada ValidateToken
+	Logout(ctx context.Context, provider, token string) error
+
+	// LogoutAll mencabut semua token dan sesi milik sub pada provider (kosong berarti provider default).
+	// actor adalah admin yang memaksa logout dan dicatat di audit log, nil jika user sendiri.
+	LogoutAll(ctx context.Context, actor *User, provider, sub string) error
+
+	// Impersonate menerbitkan token singkat bagi actor untuk bertindak sebagai user lain
+	Impersonate(ctx context.Context, actor *User, request *ImpersonationRequest) (*Impersonation, error)
+
+	// ResolveImpersonation mengembalikan user target dengan Actor terisi dari token impersonasi
+	ResolveImpersonation(ctx context.Context, token string) (*User, error)
+
+	// EndImpersonation mencabut token impersonasi sebelum masa berlakunya habis
+	EndImpersonation(ctx context.Context, token string) error
+
+	// CacheStats mengembalikan statistik cache validasi token
+	CacheStats() CacheStats
+
+	// SessionConfig mengembalikan konfigurasi mode sesi
+	SessionConfig() SessionConfig
+
+	// CreateSession menyimpan token hasil login sebagai sesi di server
+	CreateSession(ctx context.Context, user *User, token *Token) (*Session, error)
+
+	// ResolveSession mengembalikan sesi yang valid, me-refresh access token jika sudah kedaluwarsa
+	ResolveSession(ctx context.Context, sessionID string) (*Session, error)
 
-	// Logout mengeluarkan pengguna dari sistem
-	Logout(ctx context.Context, token string) error
+	// LogoutSession mencabut token milik sesi lalu menghapus sesi
+	LogoutSession(ctx context.Context, sessionID string) error
 }
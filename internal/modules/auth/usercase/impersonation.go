@@ -0,0 +1,148 @@
+package usecase
+
+import (
+	"context"
+	"djiroutine-go-clean-architecture/internal/entity"
+	"djiroutine-go-clean-architecture/internal/modules/auth"
+	"djiroutine-go-clean-architecture/pkg/errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Impersonate menerbitkan token singkat bagi actor untuk bertindak sebagai user lain
+func (uc *authUseCase) Impersonate(ctx context.Context, actor *auth.User, request *auth.ImpersonationRequest) (*auth.Impersonation, error) {
+	if actor == nil {
+		return nil, errors.AuthError("Unauthorized", nil)
+	}
+	if actor.APIKeyID != 0 {
+		return nil, errors.BadRequestError("API keys cannot impersonate users", nil)
+	}
+	if actor.Actor != nil {
+		return nil, errors.BadRequestError("Impersonation cannot be started from an impersonated session", nil)
+	}
+
+	if request.UserID <= 0 {
+		return nil, errors.FieldErr("user_id", errors.ErrIsRequired)
+	}
+	if strings.TrimSpace(request.Reason) == "" {
+		return nil, errors.FieldErr("reason", errors.ErrIsRequired)
+	}
+	if request.UserID == actor.LocalID {
+		return nil, errors.BadRequestError("You cannot impersonate yourself", nil)
+	}
+
+	ttl := uc.config.Impersonation.TTL
+	if request.TTL < 0 || time.Duration(request.TTL)*time.Second > uc.config.Impersonation.MaxTTL {
+		return nil, errors.BadRequestError("ttl must be between 0 and "+strconv.Itoa(int(uc.config.Impersonation.MaxTTL.Seconds()))+" seconds, 0 meaning the default", nil)
+	}
+	if request.TTL > 0 {
+		ttl = time.Duration(request.TTL) * time.Second
+	}
+
+	target, err := uc.userUseCase.GetUser(ctx, request.UserID)
+	if err == errors.ErrNotFound {
+		return nil, errors.NotFoundError("User not found", nil)
+	}
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to load user", err)
+	}
+
+	random, err := generateRandomString(32)
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to generate impersonation token", err)
+	}
+	token := auth.ImpersonationTokenPrefix + random
+
+	now := time.Now()
+	impersonation := &auth.Impersonation{
+		User: &auth.User{
+			ID:                  strconv.Itoa(target.ID),
+			Provider:            auth.ImpersonationProvider,
+			Email:               target.Email,
+			Name:                target.Username,
+			LocalID:             target.ID,
+			ImpersonationScopes: request.Scopes,
+		},
+		Actor:     actor,
+		Reason:    request.Reason,
+		Scopes:    request.Scopes,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	if err := uc.authRepo.SaveImpersonation(ctx, hashToken(token), impersonation, ttl); err != nil {
+		return nil, errors.InternalServerError("Failed to save impersonation", err)
+	}
+
+	uc.recordImpersonation(ctx, entity.AuditEventImpersonationStart, impersonation, impersonation.Reason)
+
+	impersonation.Token = token
+	return impersonation, nil
+}
+
+// ResolveImpersonation mengembalikan user target dengan Actor terisi dari token impersonasi
+func (uc *authUseCase) ResolveImpersonation(ctx context.Context, token string) (*auth.User, error) {
+	tokenHash := hashToken(token)
+
+	impersonation, err := uc.authRepo.GetImpersonation(ctx, tokenHash)
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to load impersonation", err)
+	}
+	if impersonation == nil {
+		return nil, errors.AuthError("Impersonation token is invalid or expired", nil)
+	}
+
+	// Logout-all milik admin ikut mengakhiri impersonasi yang dimulainya
+	revokedBefore, err := uc.authRepo.GetRevokedBefore(ctx, userKey(impersonation.Actor.Provider, impersonation.Actor.ID))
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to check impersonation revocation", err)
+	}
+	if !revokedBefore.IsZero() && impersonation.CreatedAt.Unix() <= revokedBefore.Unix() {
+		uc.authRepo.DeleteImpersonation(ctx, tokenHash)
+		return nil, errors.AuthError("Impersonation has been revoked", nil)
+	}
+
+	user := impersonation.User
+	user.Actor = impersonation.Actor
+
+	return user, nil
+}
+
+// EndImpersonation mencabut token impersonasi; token yang sudah tidak ada dianggap berhasil
+func (uc *authUseCase) EndImpersonation(ctx context.Context, token string) error {
+	tokenHash := hashToken(token)
+
+	impersonation, err := uc.authRepo.GetImpersonation(ctx, tokenHash)
+	if err != nil {
+		return errors.InternalServerError("Failed to load impersonation", err)
+	}
+	if impersonation == nil {
+		return nil
+	}
+
+	if err := uc.authRepo.DeleteImpersonation(ctx, tokenHash); err != nil {
+		return errors.InternalServerError("Failed to end impersonation", err)
+	}
+
+	uc.recordImpersonation(ctx, entity.AuditEventImpersonationEnd, impersonation, "")
+
+	return nil
+}
+
+// recordImpersonation mencatat awal atau akhir impersonasi ke audit log
+func (uc *authUseCase) recordImpersonation(ctx context.Context, eventType string, impersonation *auth.Impersonation, detail string) {
+	if uc.auditLog == nil {
+		return
+	}
+
+	uc.auditLog.Record(ctx, &entity.AuditEvent{
+		EventType: eventType,
+		Provider:  auth.ImpersonationProvider,
+		Subject:   impersonation.User.ID,
+		Email:     impersonation.User.Email,
+		Actor:     userKey(impersonation.Actor.Provider, impersonation.Actor.ID),
+		Outcome:   entity.AuditOutcomeSuccess,
+		Detail:    detail,
+	})
+}
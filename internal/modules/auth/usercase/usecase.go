@@ -3,50 +3,280 @@ package usecase
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"djiroutine-go-clean-architecture/internal/entity"
+	"djiroutine-go-clean-architecture/internal/modules/audit"
 	"djiroutine-go-clean-architecture/internal/modules/auth"
+	"djiroutine-go-clean-architecture/internal/modules/user"
 	"djiroutine-go-clean-architecture/pkg/errors"
+	"djiroutine-go-clean-architecture/pkg/logger"
 	"djiroutine-go-clean-architecture/pkg/sso"
 	"encoding/base64"
+	"encoding/hex"
+	stderrors "errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type authUseCase struct {
-	oauthClient *sso.OAuth2Client
+	providers   *sso.Registry
+	authRepo    auth.AuthRepository
+	userUseCase user.UseCase
+	auditLog    audit.UseCase
+	config      auth.Config
+	log         logger.Logger
+
+	cacheHits   int64
+	cacheMisses int64
+
+	// sessionLocks menyerialisasi refresh token per sesi di instance ini
+	sessionLocks keyedMutex
 }
 
 // NewAuthUseCase membuat instance baru dari auth use case
-func NewAuthUseCase(oauthClient *sso.OAuth2Client) auth.UseCase {
+func NewAuthUseCase(providers *sso.Registry, authRepo auth.AuthRepository, userUseCase user.UseCase, auditLog audit.UseCase, config auth.Config, log logger.Logger) auth.UseCase {
+	if config.RevocationTTL == 0 {
+		config.RevocationTTL = auth.DefaultRevocationTTL
+	}
+	if config.OpaqueCacheTTL == 0 {
+		config.OpaqueCacheTTL = auth.DefaultOpaqueCacheTTL
+	}
+	if config.Impersonation.TTL == 0 {
+		config.Impersonation.TTL = auth.DefaultImpersonationConfig.TTL
+	}
+	if config.Impersonation.MaxTTL == 0 {
+		config.Impersonation.MaxTTL = auth.DefaultImpersonationConfig.MaxTTL
+	}
+
 	return &authUseCase{
-		oauthClient: oauthClient,
+		providers:   providers,
+		authRepo:    authRepo,
+		userUseCase: userUseCase,
+		auditLog:    auditLog,
+		config:      config,
+		log:         log,
+	}
+}
+
+// provider mengembalikan provider berdasarkan nama; nama kosong berarti provider default
+func (uc *authUseCase) provider(name string) (sso.Provider, error) {
+	provider, ok := uc.providers.Get(name)
+	if !ok {
+		return nil, errors.NotFoundError("Unknown login provider", nil)
+	}
+	return provider, nil
+}
+
+// recordEvent mencatat event autentikasi ke audit log secara asinkron; err nil berarti berhasil
+func (uc *authUseCase) recordEvent(ctx context.Context, eventType, provider string, user *auth.User, err error) {
+	if uc.auditLog == nil {
+		return
+	}
+
+	event := &entity.AuditEvent{
+		EventType: eventType,
+		Provider:  provider,
+		Outcome:   entity.AuditOutcomeSuccess,
+	}
+	if user != nil {
+		event.Subject = user.ID
+		event.Email = user.Email
+		if user.Provider != "" {
+			event.Provider = user.Provider
+		}
+		event.Actor = user.ActorID()
+	}
+	if err != nil {
+		reason := err.Error()
+		event.Outcome = entity.AuditOutcomeFailure
+		event.Reason = &reason
 	}
+
+	uc.auditLog.Record(ctx, event)
+}
+
+// Providers mengembalikan nama provider login yang tersedia
+func (uc *authUseCase) Providers() []string {
+	return uc.providers.Names()
+}
+
+// hashToken menghasilkan key cache dari token agar token asli tidak tersimpan di Redis
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
 // ValidateToken memvalidasi token dan mengembalikan informasi pengguna
-func (uc *authUseCase) ValidateToken(ctx context.Context, token string) (*auth.User, error) {
-	// Verifikasi token menggunakan SDK OAuth
-	if !uc.oauthClient.IsAuthenticated(token) {
-		return nil, errors.AuthError("Invalid or expired token", nil)
+func (uc *authUseCase) ValidateToken(ctx context.Context, providerName, token string) (*auth.User, error) {
+	log := "modules.auth.usecase.ValidateToken: %s"
+	tokenHash := hashToken(token)
+
+	// Cek cache terlebih dahulu; error Redis diperlakukan sebagai miss
+	cached, err := uc.authRepo.GetCachedUser(ctx, tokenHash)
+	if err != nil {
+		uc.log.Warn(log, err.Error())
+	}
+	if cached != nil {
+		atomic.AddInt64(&uc.cacheHits, 1)
+		// Token milik provider lain tidak boleh diteruskan ke provider yang disebut klien
+		if providerName != "" && cached.Provider != providerName {
+			return nil, errors.AuthError("Invalid or expired token", sso.ErrUnknownProvider).WithCode(errors.CodeInvalidToken)
+		}
+		if err := uc.checkRevoked(ctx, tokenHash, cached); err != nil {
+			return nil, err
+		}
+		return cached, nil
+	}
+	atomic.AddInt64(&uc.cacheMisses, 1)
+
+	// Token hanya dikirim ke satu provider: sesuai iss untuk JWT, atau provider yang disebut
+	// klien/sesi untuk token opaque. Token yang tidak cocok dengan provider mana pun ditolak.
+	provider, err := uc.providers.ProviderForToken(token, providerName)
+	if stderrors.Is(err, sso.ErrNoProviders) {
+		return nil, errors.InternalServerError("No login provider is configured", err)
+	}
+	if err != nil {
+		return nil, errors.AuthError("Invalid or expired token", err).WithCode(errors.CodeInvalidToken)
 	}
 
-	// Dapatkan informasi user dari token
-	userInfo, err := uc.oauthClient.GetUserInfo(token)
+	// Verifikasi token secara lokal (JWT) atau melalui userinfo (token opaque)
+	userInfo, claims, err := provider.VerifyAccessToken(ctx, token)
 	if err != nil {
-		return nil, errors.InternalServerError("Failed to get user information", err)
+		// SSO yang tidak bisa dihubungi bukan kesalahan token, klien boleh mencoba lagi
+		if sso.IsUnavailable(err) {
+			return nil, errors.InternalServerError("Identity provider is unavailable", err)
+		}
+		return nil, errors.AuthError("Invalid or expired token", err).WithCode(errors.CodeInvalidToken)
 	}
 
 	// Konversi dari sso.UserInfo ke auth.User
 	user := &auth.User{
-		ID:      userInfo.Sub,
-		Email:   userInfo.Email,
-		Name:    userInfo.Name,
-		Profile: userInfo.Profile,
+		ID:       userInfo.Sub,
+		Provider: provider.Name(),
+		Email:    userInfo.Email,
+		Name:     userInfo.Name,
+		Profile:  userInfo.Profile,
+
+		EmailVerified: bool(userInfo.EmailVerified),
+	}
+	if claims != nil {
+		user.Scopes = strings.Fields(claims.Scope)
+	}
+
+	// Catat iat token sebelum cek pencabutan agar logout-all berikutnya bisa menolaknya
+	if err := uc.recordIssuedAt(ctx, tokenHash, claims); err != nil {
+		return nil, errors.InternalServerError("Failed to record token", err)
+	}
+	if err := uc.checkRevoked(ctx, tokenHash, user); err != nil {
+		return nil, err
+	}
+
+	// Hubungkan dengan user lokal; user baru dibuat jika token diterbitkan di luar alur callback
+	localUser, err := uc.userUseCase.ResolveExternalUser(ctx, toExternalUser(user))
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to resolve local user", err)
+	}
+	user.LocalID = localUser.ID
+
+	// TTL cache tidak boleh melebihi masa berlaku token. Kedaluwarsa token opaque hanya diketahui
+	// jika token diterbitkan lewat aplikasi ini; selain itu cache dibatasi OpaqueCacheTTL.
+	ttl := uc.config.CacheTTL
+	var expiry time.Time
+	if claims != nil {
+		expiry = claims.Expiry()
+	} else {
+		if expiry, err = uc.authRepo.GetTokenExpiry(ctx, tokenHash); err != nil {
+			uc.log.Warn(log, err.Error())
+		}
+		if expiry.IsZero() && uc.config.OpaqueCacheTTL < ttl {
+			ttl = uc.config.OpaqueCacheTTL
+		}
+	}
+	if !expiry.IsZero() {
+		if untilExpiry := time.Until(expiry); untilExpiry < ttl {
+			ttl = untilExpiry
+		}
+	}
+
+	if ttl > 0 {
+		if err := uc.authRepo.CacheUser(ctx, tokenHash, user, ttl); err != nil {
+			uc.log.Warn(log, err.Error())
+		}
 	}
 
 	return user, nil
 }
 
+// userKey adalah kunci logout-all per user; sub hanya unik di dalam satu provider
+func userKey(provider, sub string) string {
+	return provider + ":" + sub
+}
+
+// recordIssuedAt menyimpan iat token JWT. Waktu pertama kali token terlihat tidak dipakai karena
+// token lama yang baru terlihat setelah logout-all akan lolos; waktu terbit token opaque hanya
+// dicatat saat diterbitkan lewat callback atau refresh (lihat recordToken).
+func (uc *authUseCase) recordIssuedAt(ctx context.Context, tokenHash string, claims *sso.Claims) error {
+	if claims == nil || claims.IssuedAt <= 0 {
+		return nil
+	}
+
+	issuedAt, err := uc.authRepo.GetTokenIssuedAt(ctx, tokenHash)
+	if err != nil || !issuedAt.IsZero() {
+		return err
+	}
+
+	return uc.authRepo.SaveTokenIssuedAt(ctx, tokenHash, time.Unix(claims.IssuedAt, 0), uc.config.RevocationTTL)
+}
+
+// checkRevoked menolak token yang ada di denylist atau terbit sebelum logout-all user. Selama
+// penanda logout-all ada, token yang waktu terbitnya tidak diketahui ikut ditolak.
+// Error store menolak request (fail closed) karena ini kontrol keamanan.
+func (uc *authUseCase) checkRevoked(ctx context.Context, tokenHash string, user *auth.User) error {
+	denied, err := uc.authRepo.IsTokenDenied(ctx, tokenHash)
+	if err != nil {
+		return errors.InternalServerError("Failed to check token revocation", err)
+	}
+	if denied {
+		return errors.AuthError("Token has been revoked", nil).WithCode(errors.CodeTokenRevoked)
+	}
+
+	revokedBefore, err := uc.authRepo.GetRevokedBefore(ctx, userKey(user.Provider, user.ID))
+	if err != nil {
+		return errors.InternalServerError("Failed to check token revocation", err)
+	}
+	if revokedBefore.IsZero() {
+		return nil
+	}
+
+	issuedAt, err := uc.authRepo.GetTokenIssuedAt(ctx, tokenHash)
+	if err != nil {
+		return errors.InternalServerError("Failed to check token revocation", err)
+	}
+	if issuedAt.IsZero() || !issuedAt.After(revokedBefore) {
+		return errors.AuthError("Token has been revoked", nil).WithCode(errors.CodeTokenRevoked)
+	}
+
+	return nil
+}
+
+// CacheStats mengembalikan statistik cache validasi token
+func (uc *authUseCase) CacheStats() auth.CacheStats {
+	return auth.CacheStats{
+		Hits:   atomic.LoadInt64(&uc.cacheHits),
+		Misses: atomic.LoadInt64(&uc.cacheMisses),
+	}
+}
+
 // generateRandomState menghasilkan string acak untuk state
 func generateRandomState() (string, error) {
-	b := make([]byte, 16)
+	return generateRandomString(16)
+}
+
+// generateRandomString menghasilkan string acak URL-safe dari n byte
+func generateRandomString(n int) (string, error) {
+	b := make([]byte, n)
 	if _, err := rand.Read(b); err != nil {
 		return "", err
 	}
@@ -54,15 +284,42 @@ func generateRandomState() (string, error) {
 }
 
 // GetAuthorizationURL menghasilkan URL otorisasi untuk login
-func (uc *authUseCase) GetAuthorizationURL(ctx context.Context) (string, string, error) {
+func (uc *authUseCase) GetAuthorizationURL(ctx context.Context, providerName, redirectTo string, scopes []string) (string, string, error) {
+	provider, err := uc.provider(providerName)
+	if err != nil {
+		return "", "", err
+	}
+
+	if !auth.ValidRedirect(redirectTo, uc.config.State.RedirectAllowList) {
+		return "", "", errors.BadRequestError("redirect_to is not allowed", nil)
+	}
+
 	// Generate random state
 	state, err := generateRandomState()
 	if err != nil {
 		return "", "", errors.InternalServerError("Failed to generate state", err)
 	}
 
+	loginState := &auth.LoginState{
+		State:      state,
+		Provider:   provider.Name(),
+		RedirectTo: redirectTo,
+		CreatedAt:  time.Now(),
+	}
+	if err := uc.authRepo.SaveLoginState(ctx, loginState, uc.config.State.TTL); err != nil {
+		return "", "", errors.InternalServerError("Failed to store state", err)
+	}
+
 	// Get authorization URL from OAuth client
-	authURL, err := uc.oauthClient.GetAuthorizationURL(state)
+	var opts []sso.AuthURLOption
+	if len(scopes) > 0 {
+		opts = append(opts, sso.WithScopes(scopes...))
+	}
+
+	authURL, err := provider.GetAuthorizationURL(ctx, state, opts...)
+	if stderrors.Is(err, sso.ErrScopeNotAllowed) {
+		return "", "", errors.BadRequestError(err.Error(), err)
+	}
 	if err != nil {
 		return "", "", errors.InternalServerError("Failed to get authorization URL", err)
 	}
@@ -70,46 +327,418 @@ func (uc *authUseCase) GetAuthorizationURL(ctx context.Context) (string, string,
 	return authURL.URL, state, nil
 }
 
+// StateConfig mengembalikan konfigurasi validasi state
+func (uc *authUseCase) StateConfig() auth.StateConfig {
+	return uc.config.State
+}
+
+// ConsumeState memvalidasi state callback terhadap cookie browser dan menandainya sudah dipakai
+func (uc *authUseCase) ConsumeState(ctx context.Context, providerName, state, stateCookie string) (loginState *auth.LoginState, err error) {
+	// Login yang gagal karena state tidak valid dicatat; yang berhasil dicatat di ProcessCallback
+	defer func() {
+		if err != nil {
+			uc.recordEvent(ctx, entity.AuditEventLogin, providerName, nil, err)
+		}
+	}()
+
+	provider, err := uc.provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	if stateCookie == "" {
+		return nil, errors.BadRequestError("State cookie is missing, start the login again from this browser", nil)
+	}
+
+	if !auth.VerifyStateCookie(uc.config.State.Secret, stateCookie, state) {
+		return nil, errors.BadRequestError("State does not match the browser that started the login", nil)
+	}
+
+	loginState, used, err := uc.authRepo.ConsumeLoginState(ctx, state, uc.config.State.TTL)
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to validate state", err)
+	}
+	if used {
+		return nil, errors.BadRequestError("State has already been used", nil)
+	}
+	if loginState == nil {
+		return nil, errors.BadRequestError("State is expired or unknown, start the login again", nil)
+	}
+	if loginState.Provider != provider.Name() {
+		return nil, errors.BadRequestError("State was issued for another login provider", nil)
+	}
+
+	return loginState, nil
+}
+
 // ProcessCallback memproses callback dari OAuth provider
-func (uc *authUseCase) ProcessCallback(ctx context.Context, code, state string) (*auth.User, string, error) {
-	// Exchange authorization code for access token
-	tokenResp, err := uc.oauthClient.GetAccessToken(code, state)
+func (uc *authUseCase) ProcessCallback(ctx context.Context, providerName, code, state string) (user *auth.User, token *auth.Token, err error) {
+	// identity diisi begitu user diketahui agar login yang gagal sesudahnya tetap tercatat dengan akunnya
+	var identity *auth.User
+	defer func() {
+		uc.recordEvent(ctx, entity.AuditEventLogin, providerName, identity, err)
+	}()
+
+	provider, err := uc.provider(providerName)
 	if err != nil {
-		return nil, "", errors.AuthError("Failed to get access token", err)
+		return nil, nil, err
 	}
+	providerName = provider.Name()
 
-	// Get user info using the access token
-	userInfo, err := uc.oauthClient.GetUserInfo(tokenResp.AccessToken)
+	// Exchange authorization code for access token
+	tokenResp, err := provider.GetAccessToken(ctx, code, state)
 	if err != nil {
-		return nil, "", errors.InternalServerError("Failed to get user info", err)
+		return nil, nil, errors.AuthError("Failed to get access token", err)
+	}
+
+	// Identitas diambil dari id_token yang sudah divalidasi; userinfo hanya dipanggil jika tidak ada
+	var userInfo *sso.UserInfo
+	if tokenResp.IDTokenClaims != nil {
+		userInfo = tokenResp.IDTokenClaims.UserInfo()
+	} else {
+		userInfo, err = provider.GetUserInfo(ctx, tokenResp.AccessToken)
+		if err != nil {
+			return nil, nil, errors.InternalServerError("Failed to get user info", err)
+		}
 	}
 
 	// Convert to auth.User
-	user := &auth.User{
-		ID:      userInfo.Sub,
-		Email:   userInfo.Email,
-		Name:    userInfo.Name,
-		Profile: userInfo.Profile,
+	user = &auth.User{
+		ID:       userInfo.Sub,
+		Provider: provider.Name(),
+		Email:    userInfo.Email,
+		Name:     userInfo.Name,
+		Profile:  userInfo.Profile,
+
+		EmailVerified: bool(userInfo.EmailVerified),
+	}
+	identity = user
+
+	// Just-in-time provisioning: buat user lokal untuk identitas baru
+	localUser, err := uc.userUseCase.ProvisionExternalUser(ctx, toExternalUser(user))
+	if err != nil {
+		return nil, nil, errors.InternalServerError("Failed to provision local user", err)
+	}
+	user.LocalID = localUser.ID
+
+	token = toToken(tokenResp, "")
+	uc.recordToken(ctx, token)
+
+	return user, token, nil
+}
+
+// toExternalUser mengonversi auth.User menjadi identitas eksternal untuk modul user
+func toExternalUser(user *auth.User) *entity.ExternalUser {
+	return &entity.ExternalUser{
+		Provider: user.Provider,
+		Subject:  user.ID,
+		Email:    user.Email,
+		Name:     user.Name,
+
+		EmailVerified: user.EmailVerified,
+	}
+}
+
+// RefreshToken menukar refresh token dengan token baru
+func (uc *authUseCase) RefreshToken(ctx context.Context, providerName, refreshToken string) (token *auth.Token, err error) {
+	defer func() {
+		uc.recordEvent(ctx, entity.AuditEventRefresh, providerName, nil, err)
+	}()
+
+	provider, err := uc.provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	providerName = provider.Name()
+
+	tokenResp, err := provider.RefreshAccessToken(ctx, refreshToken)
+	if err != nil {
+		if stderrors.Is(err, sso.ErrInvalidGrant) {
+			return nil, errors.AuthError("Refresh token is invalid, expired or already used", err)
+		}
+		return nil, errors.InternalServerError("Failed to refresh token", err)
+	}
+
+	token = toToken(tokenResp, refreshToken)
+	uc.recordToken(ctx, token)
+
+	return token, nil
+}
+
+// recordToken menyimpan waktu terbit access token opaque agar logout-all bisa menolaknya, dan
+// waktu kedaluwarsanya agar cache validasinya tidak melebihi masa berlaku token. JWT tidak
+// perlu karena membawa iat dan exp sendiri.
+func (uc *authUseCase) recordToken(ctx context.Context, token *auth.Token) {
+	log := "modules.auth.usecase.recordToken: %s"
+
+	if sso.IsJWT(token.AccessToken) {
+		return
+	}
+	tokenHash := hashToken(token.AccessToken)
+
+	if err := uc.authRepo.SaveTokenIssuedAt(ctx, tokenHash, time.Now(), uc.config.RevocationTTL); err != nil {
+		uc.log.Warn(log, err.Error())
+	}
+
+	if token.ExpiresAt.IsZero() {
+		return
+	}
+	if err := uc.authRepo.SaveTokenExpiry(ctx, tokenHash, token.ExpiresAt, time.Until(token.ExpiresAt)); err != nil {
+		uc.log.Warn(log, err.Error())
+	}
+}
+
+// toToken mengonversi respons token SSO; jika provider tidak merotasi refresh token,
+// refresh token sebelumnya tetap dipakai
+func toToken(tokenResp *sso.TokenResponse, previousRefreshToken string) *auth.Token {
+	token := &auth.Token{
+		AccessToken:  tokenResp.AccessToken,
+		TokenType:    tokenResp.TokenType,
+		RefreshToken: tokenResp.RefreshToken,
+		ExpiresIn:    tokenResp.ExpiresIn,
+		Scope:        tokenResp.Scope,
+	}
+
+	if tokenResp.ExpiresIn > 0 {
+		token.ExpiresAt = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	}
+
+	if previousRefreshToken != "" {
+		if token.RefreshToken == "" {
+			token.RefreshToken = previousRefreshToken
+		} else {
+			token.RefreshTokenRotated = token.RefreshToken != previousRefreshToken
+		}
 	}
 
-	return user, tokenResp.AccessToken, nil
+	return token
 }
 
 // Logout mengeluarkan pengguna dari sistem
-func (uc *authUseCase) Logout(ctx context.Context, token string) error {
-	// Extract user ID from token to use as session key
-	userInfo, err := uc.oauthClient.GetUserInfo(token)
+func (uc *authUseCase) Logout(ctx context.Context, providerName, token string) (err error) {
+	// Token impersonasi tidak dikenal SSO, logout berarti mengakhiri impersonasi
+	if auth.IsImpersonationToken(token) {
+		return uc.EndImpersonation(ctx, token)
+	}
+
+	var user *auth.User
+	defer func() {
+		uc.recordEvent(ctx, entity.AuditEventLogout, "", user, err)
+	}()
+
+	// Resolve provider and user ID from token to use as session key
+	user, err = uc.ValidateToken(ctx, providerName, token)
+	if err != nil {
+		return err
+	}
+
+	// Token masuk denylist dan dihapus dari cache sebelum revoke di SSO, agar langsung ditolak
+	// walaupun revoke gagal atau SSO masih menganggapnya valid
+	tokenHash := hashToken(token)
+	if err := uc.authRepo.DenyToken(ctx, tokenHash, uc.config.RevocationTTL); err != nil {
+		return errors.InternalServerError("Failed to revoke token", err)
+	}
+	if err := uc.authRepo.DeleteCachedUser(ctx, tokenHash); err != nil {
+		uc.log.Error("modules.auth.usecase.Logout: %s", err.Error())
+	}
+
+	provider, err := uc.provider(user.Provider)
 	if err != nil {
-		return errors.InternalServerError("Failed to get user info for logout", err)
+		return err
 	}
 
 	// Use user ID as session key
-	sessionKey := userInfo.Sub
+	sessionKey := user.ID
 
-	err = uc.oauthClient.Logout(token, sessionKey)
+	err = provider.Logout(ctx, token, sessionKey)
 	if err != nil {
 		return errors.InternalServerError("Failed to logout", err)
 	}
 
 	return nil
 }
+
+// LogoutAll mencabut semua token dan sesi user yang terbit sampai saat ini
+func (uc *authUseCase) LogoutAll(ctx context.Context, actor *auth.User, providerName, sub string) (err error) {
+	defer func() {
+		uc.recordEvent(ctx, entity.AuditEventLogoutAll, providerName, &auth.User{ID: sub, Actor: actor}, err)
+	}()
+
+	provider, err := uc.provider(providerName)
+	if err != nil {
+		return err
+	}
+	providerName = provider.Name()
+
+	if sub == "" {
+		return errors.BadRequestError("sub is required", nil)
+	}
+
+	if err := uc.authRepo.SetRevokedBefore(ctx, userKey(provider.Name(), sub), time.Now(), uc.config.RevocationTTL); err != nil {
+		return errors.InternalServerError("Failed to revoke sessions", err)
+	}
+
+	return nil
+}
+
+// SessionConfig mengembalikan konfigurasi mode sesi
+func (uc *authUseCase) SessionConfig() auth.SessionConfig {
+	return uc.config.Session
+}
+
+// CreateSession menyimpan token hasil login sebagai sesi di server
+func (uc *authUseCase) CreateSession(ctx context.Context, user *auth.User, token *auth.Token) (*auth.Session, error) {
+	sessionID, err := generateRandomString(32)
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to generate session ID", err)
+	}
+
+	csrfToken, err := generateRandomString(32)
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to generate CSRF token", err)
+	}
+
+	session := &auth.Session{
+		ID:        sessionID,
+		User:      user,
+		Token:     token,
+		CSRFToken: csrfToken,
+		CreatedAt: time.Now(),
+	}
+
+	if err := uc.authRepo.SaveSession(ctx, session, uc.config.Session.TTL); err != nil {
+		return nil, errors.InternalServerError("Failed to save session", err)
+	}
+
+	return session, nil
+}
+
+// ResolveSession mengembalikan sesi yang valid, me-refresh access token jika sudah kedaluwarsa
+func (uc *authUseCase) ResolveSession(ctx context.Context, sessionID string) (*auth.Session, error) {
+	session, err := uc.loadSession(ctx, sessionID)
+	if err != nil || !needsRefresh(session.Token) {
+		return session, err
+	}
+
+	// Refresh dijalankan satu per satu per sesi; request yang menunggu membaca ulang sesi dan
+	// memakai token hasil refresh sebelumnya, karena refresh token yang dirotasi hanya berlaku sekali
+	unlock := uc.sessionLocks.Lock(sessionID)
+	defer unlock()
+
+	session, err = uc.loadSession(ctx, sessionID)
+	if err != nil || !needsRefresh(session.Token) {
+		return session, err
+	}
+
+	if session.Token.RefreshToken == "" {
+		uc.authRepo.DeleteSession(ctx, sessionID)
+		return nil, errors.AuthError("Session expired", nil)
+	}
+
+	token, err := uc.RefreshToken(ctx, session.User.Provider, session.Token.RefreshToken)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok && appErr.Code == 401 {
+			// Instance lain mungkin sudah me-refresh sesi ini dengan refresh token yang sama
+			current, getErr := uc.authRepo.GetSession(ctx, sessionID)
+			if getErr == nil && current != nil && current.Token.RefreshToken != session.Token.RefreshToken {
+				return current, nil
+			}
+			uc.authRepo.DeleteSession(ctx, sessionID)
+		}
+		return nil, err
+	}
+
+	session.Token = token
+	if err := uc.authRepo.SaveSession(ctx, session, uc.config.Session.TTL); err != nil {
+		return nil, errors.InternalServerError("Failed to save session", err)
+	}
+
+	return session, nil
+}
+
+// loadSession membaca sesi dan menolak sesi yang tidak ada atau dicabut lewat logout-all
+func (uc *authUseCase) loadSession(ctx context.Context, sessionID string) (*auth.Session, error) {
+	session, err := uc.authRepo.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to load session", err)
+	}
+	if session == nil {
+		return nil, errors.AuthError("Session not found or expired", nil)
+	}
+
+	// Sesi yang dibuat sebelum logout-all ikut dicabut, termasuk token hasil refresh-nya
+	revokedBefore, err := uc.authRepo.GetRevokedBefore(ctx, userKey(session.User.Provider, session.User.ID))
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to check session revocation", err)
+	}
+	if !revokedBefore.IsZero() && session.CreatedAt.Unix() <= revokedBefore.Unix() {
+		uc.authRepo.DeleteSession(ctx, sessionID)
+		return nil, errors.AuthError("Session has been revoked", nil).WithCode(errors.CodeTokenRevoked)
+	}
+
+	return session, nil
+}
+
+// needsRefresh bernilai true jika access token sesi hampir kedaluwarsa; refresh dilakukan
+// sedikit lebih awal agar token tidak kedaluwarsa di tengah request
+func needsRefresh(token *auth.Token) bool {
+	return !token.ExpiresAt.IsZero() && !time.Now().Add(30*time.Second).Before(token.ExpiresAt)
+}
+
+// LogoutSession mencabut token milik sesi lalu menghapus sesi
+func (uc *authUseCase) LogoutSession(ctx context.Context, sessionID string) error {
+	session, err := uc.authRepo.GetSession(ctx, sessionID)
+	if err != nil {
+		return errors.InternalServerError("Failed to load session", err)
+	}
+	if session == nil {
+		return nil
+	}
+
+	logoutErr := uc.Logout(ctx, session.User.Provider, session.Token.AccessToken)
+
+	// Sesi tetap dihapus walaupun logout di SSO gagal agar cookie sesi tidak bisa dipakai lagi
+	if err := uc.authRepo.DeleteSession(ctx, sessionID); err != nil {
+		return errors.InternalServerError("Failed to delete session", err)
+	}
+
+	return logoutErr
+}
+
+// keyedMutex menyediakan satu mutex per key; mutex dilepas dari map setelah tidak ada yang memakai
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	users int
+}
+
+// Lock mengunci key dan mengembalikan fungsi untuk membukanya
+func (k *keyedMutex) Lock(key string) func() {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = map[string]*keyedLock{}
+	}
+	lock, ok := k.locks[key]
+	if !ok {
+		lock = &keyedLock{}
+		k.locks[key] = lock
+	}
+	lock.users++
+	k.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		k.mu.Lock()
+		lock.users--
+		if lock.users == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
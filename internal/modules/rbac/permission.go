@@ -0,0 +1,46 @@
+package rbac
+
+import "strings"
+
+// MatchPermission reports whether any granted permission covers the required one,
+// honouring "resource:*" and "*" wildcards
+func MatchPermission(granted []string, required string) bool {
+	resource := required
+	if i := strings.Index(required, ":"); i != -1 {
+		resource = required[:i]
+	}
+
+	for _, p := range granted {
+		if p == required || p == "*" || p == resource+":*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IntersectPermissions returns the permissions granted by both a and b. Of two overlapping
+// grants the narrower one is kept, e.g. "users:*" and "users:read" give "users:read".
+func IntersectPermissions(a, b []string) []string {
+	res := make([]string, 0, len(a))
+	add := func(permissions, other []string) {
+		for _, p := range permissions {
+			if MatchPermission(other, p) && !contains(res, p) {
+				res = append(res, p)
+			}
+		}
+	}
+	add(a, b)
+	add(b, a)
+
+	return res
+}
+
+func contains(permissions []string, permission string) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
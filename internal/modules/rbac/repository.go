@@ -0,0 +1,8 @@
+package rbac
+
+import "context"
+
+type Repository interface {
+	// GetPermissionsByUserID returns the permission codes granted through the roles of the local user
+	GetPermissionsByUserID(ctx context.Context, userID int) ([]string, error)
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"djiroutine-go-clean-architecture/internal/entity"
+	"djiroutine-go-clean-architecture/pkg/config"
+	"djiroutine-go-clean-architecture/pkg/logger"
+)
+
+type RBACRepository struct {
+	db  config.DBService
+	log logger.Logger
+}
+
+func NewRBACRepository(db config.DBService, log logger.Logger) *RBACRepository {
+	return &RBACRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *RBACRepository) GetPermissionsByUserID(ctx context.Context, userID int) ([]string, error) {
+	log := "modules.rbac.repository.GetPermissionsByUserID: %s"
+
+	var permissions []string
+	err := r.db.GetConnection().WithContext(ctx).
+		Model(&entity.Permission{}).
+		Distinct("rbac_permission.code").
+		Joins("JOIN rbac_role_permission ON rbac_role_permission.permission_id = rbac_permission.id").
+		Joins("JOIN rbac_user_role ON rbac_user_role.role_id = rbac_role_permission.role_id").
+		Where("rbac_user_role.user_id = ?", userID).
+		Pluck("rbac_permission.code", &permissions).Error
+	if err != nil {
+		r.log.Error(log, err)
+
+		return nil, err
+	}
+
+	return permissions, nil
+}
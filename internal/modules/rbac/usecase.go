@@ -0,0 +1,17 @@
+package rbac
+
+import (
+	"context"
+	"djiroutine-go-clean-architecture/internal/modules/auth"
+)
+
+type UseCase interface {
+	// GetPermissions returns the permissions from the user's token scopes and assigned roles.
+	// API key principals only get their key scopes; impersonated users are narrowed to the impersonation scopes
+	// and to the permissions of the actor.
+	GetPermissions(ctx context.Context, user *auth.User) ([]string, error)
+
+	// HasPermission reports whether the user is granted the permission, honouring "resource:*" and "*" wildcards.
+	// Impersonated users never get permissions to impersonate, revoke sessions or write API keys.
+	HasPermission(ctx context.Context, user *auth.User, permission string) (bool, error)
+}
@@ -0,0 +1,95 @@
+package usecase
+
+import (
+	"context"
+	"djiroutine-go-clean-architecture/internal/modules/apikey"
+	"djiroutine-go-clean-architecture/internal/modules/auth"
+	"djiroutine-go-clean-architecture/internal/modules/rbac"
+	"djiroutine-go-clean-architecture/pkg/logger"
+	"time"
+)
+
+// impersonationDenied are never granted while impersonating, whatever the target's roles
+var impersonationDenied = []string{auth.ImpersonatePermission, "sessions:revoke", "apikeys:write", apikey.AdminPermission}
+
+type RBACUsecase struct {
+	rbacRepo       rbac.Repository
+	contextTimeout time.Duration
+	log            logger.Logger
+}
+
+func NewRBACUsecase(rbacRepo rbac.Repository, timeout time.Duration, log logger.Logger) rbac.UseCase {
+	return &RBACUsecase{
+		rbacRepo:       rbacRepo,
+		contextTimeout: timeout,
+		log:            log,
+	}
+}
+
+func (u *RBACUsecase) GetPermissions(ctx context.Context, user *auth.User) ([]string, error) {
+	log := "modules.rbac.usecase.GetPermissions: %s"
+
+	permissions := append([]string{}, user.Scopes...)
+
+	// API keys are limited to their scopes and do not inherit the owner's roles
+	if user.LocalID == 0 || user.APIKeyID != 0 {
+		return permissions, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, u.contextTimeout)
+	defer cancel()
+
+	rolePermissions, err := u.rbacRepo.GetPermissionsByUserID(ctx, user.LocalID)
+	if err != nil {
+		u.log.Error(log, err.Error())
+
+		return nil, err
+	}
+
+	permissions = append(permissions, rolePermissions...)
+
+	// Impersonation scopes narrow the target's permissions instead of adding to them
+	if user.Actor != nil && len(user.ImpersonationScopes) > 0 {
+		scoped := make([]string, 0, len(user.ImpersonationScopes))
+		for _, scope := range user.ImpersonationScopes {
+			if rbac.MatchPermission(permissions, scope) {
+				scoped = append(scoped, scope)
+			}
+		}
+		permissions = scoped
+	}
+
+	// An impersonation never grants more than the actor holds
+	if user.Actor != nil {
+		actorPermissions, err := u.GetPermissions(ctx, user.Actor)
+		if err != nil {
+			return nil, err
+		}
+		permissions = rbac.IntersectPermissions(permissions, actorPermissions)
+	}
+
+	return permissions, nil
+}
+
+func (u *RBACUsecase) HasPermission(ctx context.Context, user *auth.User, permission string) (bool, error) {
+	if user.Actor != nil {
+		for _, denied := range impersonationDenied {
+			if permission == denied {
+				return false, nil
+			}
+		}
+	}
+
+	// Token scopes are checked first so scoped tokens do not hit the database; impersonated
+	// users always go through GetPermissions to be bounded by the actor
+	if user.Actor == nil && rbac.MatchPermission(user.Scopes, permission) {
+		return true, nil
+	}
+
+	permissions, err := u.GetPermissions(ctx, user)
+	if err != nil {
+		return false, err
+	}
+
+	return rbac.MatchPermission(permissions, permission), nil
+}
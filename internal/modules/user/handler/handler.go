@@ -8,6 +8,7 @@ import (
 	"djiroutine-go-clean-architecture/pkg/errors"
 	"djiroutine-go-clean-architecture/pkg/helper"
 	"djiroutine-go-clean-architecture/pkg/logger"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 )
@@ -28,48 +29,128 @@ func (h *UserHandler) ListUsers(c echo.Context) error {
 	log := "auth.handler.MasterHandler.ListUser: %s"
 
 	response := new(pkg.ResponseWithPaginator)
-	request := new(entity.RequestList)
 
 	ctx := c.Request().Context()
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
-	dec, err := helper.QueryParamDecode(c, request)
+	request, err := helper.Bind[entity.UserRequestList](c)
 	if err != nil {
-		response.MappingResponseError(helper.GetStatusCode(errors.ErrBadParamInput), err.Error())
-
-		return c.JSON(response.Code, response)
+		return err
 	}
+
 	_, _, offset := helper.Pagination(helper.IntToString(*request.Page), helper.IntToString(*request.Limit))
-	request = dec.(*entity.RequestList)
+	request.Offset = helper.IntToIntNullable(offset)
 
-	if request.Limit == nil {
-		request = request.MappingDefaultPage()
+	res, total, err := h.UserUsecase.ListUsers(ctx, request)
+	if err != nil {
+		return err
 	}
 
-	validation := request.MappingToGlobalValidation()
-	checkQueryparams, message := helper.GlobalValidationQueryParams(validation)
+	h.Log.Info(log, helper.JsonString(res))
+
+	response.MappingResponseSuccess("Get users list successfull", res)
+	response.MappingPagination(int32(*request.Page), int32(*request.Limit), int(total), len(res), response.Response)
 
-	if !checkQueryparams {
-		response.MappingResponseError(helper.GetStatusCode(errors.ErrBadParamInput), message)
-		return c.JSON(response.Code, response)
+	return c.JSON(response.Code, response)
+}
+
+func (h *UserHandler) GetUser(c echo.Context) error {
+	response := new(pkg.Response)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.FieldErr("id", errors.ErrInvalidDataType)
 	}
 
-	request.Offset = helper.IntToIntNullable(offset)
+	res, err := h.UserUsecase.GetUser(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
 
-	res, total, err := h.UserUsecase.ListUsers(ctx, request)
+	response.MappingResponseSuccess("Get user successfull", res)
+
+	return c.JSON(response.Code, response)
+}
+
+func (h *UserHandler) CreateUser(c echo.Context) error {
+	response := new(pkg.Response)
+
+	request, err := helper.Bind[entity.UserRequest](c)
 	if err != nil {
-		h.Log.Error("["+helper.ErrId()+"]  "+log, err.Error())
-		response.MappingResponseError(helper.GetStatusCode(errors.ErrBadParamInput), err.Error())
+		return err
+	}
 
-		return c.JSON(response.Code, response)
+	res, err := h.UserUsecase.CreateUser(c.Request().Context(), request)
+	if err != nil {
+		return err
 	}
 
-	h.Log.Info(log, helper.JsonString(res))
+	response.MappingResponseCreated("Create user successfull", res)
 
-	response.MappingResponseSuccess("Get users list successfull", res)
-	response.MappingPagination(int32(*request.Page), int32(*request.Limit), int(total), len(res), response.Response)
+	return c.JSON(response.Code, response)
+}
+
+func (h *UserHandler) UpdateUser(c echo.Context) error {
+	response := new(pkg.Response)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.FieldErr("id", errors.ErrInvalidDataType)
+	}
+
+	request, err := helper.Bind[entity.UserRequest](c)
+	if err != nil {
+		return err
+	}
+
+	res, err := h.UserUsecase.UpdateUser(c.Request().Context(), id, request)
+	if err != nil {
+		return err
+	}
+
+	response.MappingResponseSuccess("Update user successfull", res)
+
+	return c.JSON(response.Code, response)
+}
+
+func (h *UserHandler) PatchUser(c echo.Context) error {
+	response := new(pkg.Response)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.FieldErr("id", errors.ErrInvalidDataType)
+	}
+
+	request, err := helper.Bind[entity.UserPatchRequest](c)
+	if err != nil {
+		return err
+	}
+
+	res, err := h.UserUsecase.PatchUser(c.Request().Context(), id, request)
+	if err != nil {
+		return err
+	}
+
+	response.MappingResponseSuccess("Patch user successfull", res)
+
+	return c.JSON(response.Code, response)
+}
+
+func (h *UserHandler) DeleteUser(c echo.Context) error {
+	response := new(pkg.Response)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.FieldErr("id", errors.ErrInvalidDataType)
+	}
+
+	if err := h.UserUsecase.DeleteUser(c.Request().Context(), id); err != nil {
+		return err
+	}
+
+	response.MappingResponseSuccess("Delete user successfull", nil)
 
 	return c.JSON(response.Code, response)
 }
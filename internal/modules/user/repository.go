@@ -3,9 +3,18 @@ package user
 import (
 	"context"
 	"djiroutine-go-clean-architecture/internal/entity"
+	"time"
 )
 
 type Repository interface {
-	ListUsers(ctx context.Context, param *entity.RequestList) ([]*entity.UserResponse, error)
-	GetTotalUsers(ctx context.Context, param *entity.RequestList) (int64, error)
+	ListUsers(ctx context.Context, filter *entity.UserFilter, limit, offset int) ([]*entity.UserResponse, int64, error)
+	GetUserByID(ctx context.Context, id int) (*entity.UserResponse, error)
+	CreateUser(ctx context.Context, user *entity.User) error
+	UpdateUser(ctx context.Context, id int, fields map[string]interface{}) error
+	DeleteUser(ctx context.Context, id int) error
+	GetUserByEmail(ctx context.Context, email string) (*entity.UserResponse, error)
+	UsernameExists(ctx context.Context, username string) (bool, error)
+	GetIdentity(ctx context.Context, provider, subject string) (*entity.UserIdentity, error)
+	CreateUserWithIdentity(ctx context.Context, user *entity.User, identity *entity.UserIdentity) error
+	UpdateIdentityLogin(ctx context.Context, id int, email *string, lastLoginAt time.Time) error
 }
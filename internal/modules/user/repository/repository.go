@@ -4,7 +4,13 @@ import (
 	"context"
 	"djiroutine-go-clean-architecture/internal/entity"
 	"djiroutine-go-clean-architecture/pkg/config"
+	"djiroutine-go-clean-architecture/pkg/errors"
 	"djiroutine-go-clean-architecture/pkg/logger"
+	"djiroutine-go-clean-architecture/pkg/query"
+	goerrors "errors"
+	"time"
+
+	"gorm.io/gorm"
 )
 
 type UserRepository struct {
@@ -19,50 +25,190 @@ func NewUserRepository(db config.DBService, log logger.Logger) *UserRepository {
 	}
 }
 
-func (r *UserRepository) ListUsers(ctx context.Context, param *entity.RequestList) ([]*entity.UserResponse, error) {
-	log := "modules.master.repository.ListUser: %s"
+// ListUsers returns the page of users matching filter and their total in one query
+func (r *UserRepository) ListUsers(ctx context.Context, filter *entity.UserFilter, limit, offset int) ([]*entity.UserResponse, int64, error) {
+	log := "modules.user.repository.ListUsers: %s"
+
+	spec := query.Spec{
+		Filters: []query.Filter{
+			query.Search(filter.Search, "first_name", "last_name"),
+			query.EqualFold("email", filter.Email),
+			query.Equal("username", filter.Username),
+			query.In("id", filter.IDs),
+			query.Gte("created_at", filter.CreatedFrom),
+			query.Lte("created_at", filter.CreatedTo),
+		},
+		Sort:        filter.Sort,
+		TieBreaker:  "id",
+		Limit:       limit,
+		Offset:      offset,
+		WindowCount: true,
+	}
 
-	var res []*entity.UserResponse
-	employee := new([]entity.User)
-	query := r.db.GetConnection().WithContext(ctx)
+	res, total, err := query.Find[entity.UserResponse](r.db.GetConnection().WithContext(ctx).Model(&entity.User{}), spec)
+	if err != nil {
+		r.log.Error(log, err)
 
-	if param.Search != nil || *param.Search != "" {
-		searchPattern := "%" + *param.Search + "%"
-		query = query.Where("LOWER(first_name) LIKE LOWER(?) OR LOWER(last_name) LIKE LOWER(?)", searchPattern, searchPattern)
+		return nil, 0, mapError(err)
 	}
 
-	if param.Limit != nil && param.Offset != nil {
-		query = query.Limit(*param.Limit).Offset(*param.Offset)
+	return res, total, nil
+}
+
+func (r *UserRepository) GetUserByID(ctx context.Context, id int) (*entity.UserResponse, error) {
+	log := "modules.user.repository.GetUserByID: %s"
+
+	res := new(entity.UserResponse)
+	err := r.db.GetConnection().WithContext(ctx).Model(&entity.User{}).Where("id = ?", id).Take(res).Error
+	if err != nil {
+		r.log.Error(log, err)
+
+		return nil, mapError(err)
 	}
 
-	err := query.Model(&employee).Find(&res).Error
+	return res, nil
+}
+
+func (r *UserRepository) CreateUser(ctx context.Context, user *entity.User) error {
+	log := "modules.user.repository.CreateUser: %s"
+
+	err := r.db.GetConnection().WithContext(ctx).Create(user).Error
 	if err != nil {
 		r.log.Error(log, err)
 
-		return nil, err
+		return mapError(err)
+	}
+
+	return nil
+}
+
+func (r *UserRepository) UpdateUser(ctx context.Context, id int, fields map[string]interface{}) error {
+	log := "modules.user.repository.UpdateUser: %s"
+
+	query := r.db.GetConnection().WithContext(ctx).Model(&entity.User{}).Where("id = ?", id).Updates(fields)
+	if query.Error != nil {
+		r.log.Error(log, query.Error)
+
+		return mapError(query.Error)
+	}
+
+	if query.RowsAffected == 0 {
+		return errors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *UserRepository) DeleteUser(ctx context.Context, id int) error {
+	log := "modules.user.repository.DeleteUser: %s"
+
+	query := r.db.GetConnection().WithContext(ctx).Where("id = ?", id).Delete(&entity.User{})
+	if query.Error != nil {
+		r.log.Error(log, query.Error)
+
+		return mapError(query.Error)
+	}
+
+	if query.RowsAffected == 0 {
+		return errors.ErrNotFound
+	}
+
+	return nil
+}
+
+// mapError translates GORM errors into the sentinel errors understood by helper.GetStatusCode
+func mapError(err error) error {
+	switch {
+	case goerrors.Is(err, gorm.ErrRecordNotFound):
+		return errors.ErrNotFound
+	case goerrors.Is(err, gorm.ErrDuplicatedKey):
+		return errors.ErrConflict
+	default:
+		return errors.ErrInternalServerError
+	}
+}
+
+func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*entity.UserResponse, error) {
+	log := "modules.user.repository.GetUserByEmail: %s"
+
+	res := new(entity.UserResponse)
+	err := r.db.GetConnection().WithContext(ctx).Model(&entity.User{}).Where("LOWER(email) = LOWER(?)", email).Order("id").Take(res).Error
+	if err != nil {
+		if !goerrors.Is(err, gorm.ErrRecordNotFound) {
+			r.log.Error(log, err)
+		}
+
+		return nil, mapError(err)
 	}
 
 	return res, nil
 }
 
-func (r *UserRepository) GetTotalUsers(ctx context.Context, param *entity.RequestList) (int64, error) {
+func (r *UserRepository) UsernameExists(ctx context.Context, username string) (bool, error) {
+	log := "modules.user.repository.UsernameExists: %s"
+
 	var total int64
-	log := "modules.master.repository.CountTotalPegawai: %s"
+	err := r.db.GetConnection().WithContext(ctx).Model(&entity.User{}).Where("username = ?", username).Count(&total).Error
+	if err != nil {
+		r.log.Error(log, err)
+
+		return false, mapError(err)
+	}
+
+	return total > 0, nil
+}
 
-	employee := new([]entity.User)
-	query := r.db.GetConnection().WithContext(ctx)
+func (r *UserRepository) GetIdentity(ctx context.Context, provider, subject string) (*entity.UserIdentity, error) {
+	log := "modules.user.repository.GetIdentity: %s"
 
-	if param.Search != nil {
-		searchPattern := "%" + *param.Search + "%"
-		query = query.Where("LOWER(first_name) LIKE LOWER(?) OR LOWER(last_name) LIKE LOWER(?)", searchPattern, searchPattern)
+	res := new(entity.UserIdentity)
+	err := r.db.GetConnection().WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).Take(res).Error
+	if err != nil {
+		if !goerrors.Is(err, gorm.ErrRecordNotFound) {
+			r.log.Error(log, err)
+		}
+
+		return nil, mapError(err)
 	}
 
-	err := query.Model(&employee).Count(&total).Error
+	return res, nil
+}
+
+// CreateUserWithIdentity creates the user when it has no ID yet, then links the identity to it in one transaction
+func (r *UserRepository) CreateUserWithIdentity(ctx context.Context, user *entity.User, identity *entity.UserIdentity) error {
+	log := "modules.user.repository.CreateUserWithIdentity: %s"
+
+	err := r.db.GetConnection().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if user.ID == 0 {
+			if err := tx.Create(user).Error; err != nil {
+				return err
+			}
+		}
+
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+	if err != nil {
+		r.log.Error(log, err)
+
+		return mapError(err)
+	}
+
+	return nil
+}
+
+func (r *UserRepository) UpdateIdentityLogin(ctx context.Context, id int, email *string, lastLoginAt time.Time) error {
+	log := "modules.user.repository.UpdateIdentityLogin: %s"
+
+	err := r.db.GetConnection().WithContext(ctx).Model(&entity.UserIdentity{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"email":         email,
+		"last_login_at": lastLoginAt,
+	}).Error
 	if err != nil {
 		r.log.Error(log, err)
 
-		return 0, err
+		return mapError(err)
 	}
 
-	return total, nil
+	return nil
 }
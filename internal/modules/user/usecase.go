@@ -5,6 +5,28 @@ import (
 	"djiroutine-go-clean-architecture/internal/entity"
 )
 
+// ProvisioningConfig controls how external identities are matched to local users
+type ProvisioningConfig struct {
+	// LinkByEmail links a new external identity to an existing user with the same email.
+	// The email must be verified by the provider and the provider listed in LinkTrustedProviders.
+	LinkByEmail bool
+	// LinkTrustedProviders are the providers whose verified emails are trusted for linking
+	LinkTrustedProviders []string
+	// LinkEmailDomains restricts email linking to these domains; empty allows any domain
+	LinkEmailDomains []string
+}
+
 type UseCase interface {
-	ListUsers(ctx context.Context, request *entity.RequestList) (res []*entity.UserResponse, total int64, err error)
+	ListUsers(ctx context.Context, request *entity.UserRequestList) (res []*entity.UserResponse, total int64, err error)
+	GetUser(ctx context.Context, id int) (*entity.UserResponse, error)
+	CreateUser(ctx context.Context, request *entity.UserRequest) (*entity.UserResponse, error)
+	UpdateUser(ctx context.Context, id int, request *entity.UserRequest) (*entity.UserResponse, error)
+	PatchUser(ctx context.Context, id int, request *entity.UserPatchRequest) (*entity.UserResponse, error)
+	DeleteUser(ctx context.Context, id int) error
+
+	// ProvisionExternalUser creates the local user of a new identity on login and records the login time.
+	// The local profile is not changed by later logins.
+	ProvisionExternalUser(ctx context.Context, external *entity.ExternalUser) (*entity.UserResponse, error)
+	// ResolveExternalUser returns the linked local user, provisioning it when the identity is new
+	ResolveExternalUser(ctx context.Context, external *entity.ExternalUser) (*entity.UserResponse, error)
 }
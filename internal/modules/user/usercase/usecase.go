@@ -4,43 +4,272 @@ import (
 	"context"
 	"djiroutine-go-clean-architecture/internal/entity"
 	"djiroutine-go-clean-architecture/internal/modules/user"
+	"djiroutine-go-clean-architecture/pkg/errors"
+	"djiroutine-go-clean-architecture/pkg/helper"
 	"djiroutine-go-clean-architecture/pkg/logger"
+	"fmt"
+	"strings"
 	"time"
 )
 
 type UserUsecase struct {
 	userRepo       user.Repository
 	contextTimeout time.Duration
+	provisioning   user.ProvisioningConfig
 	log            logger.Logger
 }
 
-func NewUserUsecase(userRepo user.Repository, timeout time.Duration, log logger.Logger) user.UseCase {
+func NewUserUsecase(userRepo user.Repository, timeout time.Duration, provisioning user.ProvisioningConfig, log logger.Logger) user.UseCase {
 	return &UserUsecase{
 		userRepo:       userRepo,
 		contextTimeout: timeout,
+		provisioning:   provisioning,
 		log:            log,
 	}
 }
 
-func (u UserUsecase) ListUsers(ctx context.Context, request *entity.RequestList) (res []*entity.UserResponse, total int64, err error) {
+func (u UserUsecase) ListUsers(ctx context.Context, request *entity.UserRequestList) (res []*entity.UserResponse, total int64, err error) {
 	log := "modules.master.usecase.ListPegawai: %s"
 
 	ctx, cancel := context.WithTimeout(ctx, u.contextTimeout)
 	defer cancel()
 
-	res, err = u.userRepo.ListUsers(ctx, request)
+	res, total, err = u.userRepo.ListUsers(ctx, request.MappingToFilter(), helper.IntNullableToInt(request.Limit), helper.IntNullableToInt(request.Offset))
 	if err != nil {
 		u.log.Error(log+"list users - ", err.Error())
 
 		return nil, 0, err
 	}
 
-	total, err = u.userRepo.GetTotalUsers(ctx, request)
+	return res, total, err
+}
+
+func (u UserUsecase) GetUser(ctx context.Context, id int) (*entity.UserResponse, error) {
+	log := "modules.user.usecase.GetUser: %s"
+
+	ctx, cancel := context.WithTimeout(ctx, u.contextTimeout)
+	defer cancel()
+
+	res, err := u.userRepo.GetUserByID(ctx, id)
 	if err != nil {
-		u.log.Error(log+"count total users - ", err.Error())
+		u.log.Error(log, err.Error())
 
-		return nil, 0, err
+		return nil, err
 	}
 
-	return res, total, err
+	return res, nil
+}
+
+func (u UserUsecase) CreateUser(ctx context.Context, request *entity.UserRequest) (*entity.UserResponse, error) {
+	log := "modules.user.usecase.CreateUser: %s"
+
+	ctx, cancel := context.WithTimeout(ctx, u.contextTimeout)
+	defer cancel()
+
+	user := request.MappingToUser()
+	if err := u.userRepo.CreateUser(ctx, user); err != nil {
+		u.log.Error(log, err.Error())
+
+		return nil, err
+	}
+
+	return u.userRepo.GetUserByID(ctx, user.ID)
+}
+
+func (u UserUsecase) UpdateUser(ctx context.Context, id int, request *entity.UserRequest) (*entity.UserResponse, error) {
+	log := "modules.user.usecase.UpdateUser: %s"
+
+	ctx, cancel := context.WithTimeout(ctx, u.contextTimeout)
+	defer cancel()
+
+	if err := u.userRepo.UpdateUser(ctx, id, request.MappingToUpdateFields()); err != nil {
+		u.log.Error(log, err.Error())
+
+		return nil, err
+	}
+
+	return u.userRepo.GetUserByID(ctx, id)
+}
+
+func (u UserUsecase) PatchUser(ctx context.Context, id int, request *entity.UserPatchRequest) (*entity.UserResponse, error) {
+	log := "modules.user.usecase.PatchUser: %s"
+
+	ctx, cancel := context.WithTimeout(ctx, u.contextTimeout)
+	defer cancel()
+
+	fields := request.MappingToUpdateFields()
+
+	// An empty patch is a no-op, but the user must still exist
+	if len(fields) == 0 {
+		return u.userRepo.GetUserByID(ctx, id)
+	}
+
+	if err := u.userRepo.UpdateUser(ctx, id, fields); err != nil {
+		u.log.Error(log, err.Error())
+
+		return nil, err
+	}
+
+	return u.userRepo.GetUserByID(ctx, id)
+}
+
+func (u UserUsecase) DeleteUser(ctx context.Context, id int) error {
+	log := "modules.user.usecase.DeleteUser: %s"
+
+	ctx, cancel := context.WithTimeout(ctx, u.contextTimeout)
+	defer cancel()
+
+	if err := u.userRepo.DeleteUser(ctx, id); err != nil {
+		u.log.Error(log, err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+func (u UserUsecase) ProvisionExternalUser(ctx context.Context, external *entity.ExternalUser) (*entity.UserResponse, error) {
+	log := "modules.user.usecase.ProvisionExternalUser: %s"
+
+	ctx, cancel := context.WithTimeout(ctx, u.contextTimeout)
+	defer cancel()
+
+	identity, err := u.userRepo.GetIdentity(ctx, external.Provider, external.Subject)
+	if err == errors.ErrNotFound {
+		return u.linkOrCreate(ctx, external)
+	}
+	if err != nil {
+		u.log.Error(log, err.Error())
+
+		return nil, err
+	}
+
+	// The local profile is managed in this app; the provider's current email is only kept on the identity
+	if err := u.userRepo.UpdateIdentityLogin(ctx, identity.ID, emailOrNil(external.Email), time.Now()); err != nil {
+		u.log.Error(log, err.Error())
+
+		return nil, err
+	}
+
+	return u.userRepo.GetUserByID(ctx, identity.UserID)
+}
+
+func (u UserUsecase) ResolveExternalUser(ctx context.Context, external *entity.ExternalUser) (*entity.UserResponse, error) {
+	log := "modules.user.usecase.ResolveExternalUser: %s"
+
+	ctx, cancel := context.WithTimeout(ctx, u.contextTimeout)
+	defer cancel()
+
+	identity, err := u.userRepo.GetIdentity(ctx, external.Provider, external.Subject)
+	if err == errors.ErrNotFound {
+		return u.linkOrCreate(ctx, external)
+	}
+	if err != nil {
+		u.log.Error(log, err.Error())
+
+		return nil, err
+	}
+
+	return u.userRepo.GetUserByID(ctx, identity.UserID)
+}
+
+// linkOrCreate links a new identity to an existing user by email when allowed, otherwise creates a new user
+func (u UserUsecase) linkOrCreate(ctx context.Context, external *entity.ExternalUser) (*entity.UserResponse, error) {
+	log := "modules.user.usecase.linkOrCreate: %s"
+
+	now := time.Now()
+	identity := &entity.UserIdentity{
+		Provider:    external.Provider,
+		Subject:     external.Subject,
+		Email:       emailOrNil(external.Email),
+		CreatedAt:   now,
+		LastLoginAt: &now,
+	}
+
+	newUser := external.MappingToUser()
+
+	if u.canLinkByEmail(external) {
+		existing, err := u.userRepo.GetUserByEmail(ctx, external.Email)
+		if err != nil && err != errors.ErrNotFound {
+			u.log.Error(log, err.Error())
+
+			return nil, err
+		}
+		if existing != nil {
+			newUser.ID = existing.ID
+		}
+	}
+
+	if newUser.ID == 0 {
+		username, err := u.uniqueUsername(ctx, newUser.Username)
+		if err != nil {
+			u.log.Error(log, err.Error())
+
+			return nil, err
+		}
+		newUser.Username = username
+	}
+
+	err := u.userRepo.CreateUserWithIdentity(ctx, newUser, identity)
+	if err == errors.ErrConflict {
+		// A concurrent login may have linked the same identity first
+		if existing, lookupErr := u.userRepo.GetIdentity(ctx, external.Provider, external.Subject); lookupErr == nil {
+			return u.userRepo.GetUserByID(ctx, existing.UserID)
+		}
+	}
+	if err != nil {
+		u.log.Error(log, err.Error())
+
+		return nil, err
+	}
+
+	return u.userRepo.GetUserByID(ctx, newUser.ID)
+}
+
+// canLinkByEmail only trusts emails verified by a provider listed in LinkTrustedProviders, since
+// anyone able to register an unverified email at a provider could otherwise take over the account
+func (u UserUsecase) canLinkByEmail(external *entity.ExternalUser) bool {
+	email := external.Email
+	if !u.provisioning.LinkByEmail || email == "" || !external.EmailVerified {
+		return false
+	}
+
+	if !helper.InArray(external.Provider, u.provisioning.LinkTrustedProviders) {
+		return false
+	}
+
+	if len(u.provisioning.LinkEmailDomains) == 0 {
+		return true
+	}
+
+	i := strings.LastIndex(email, "@")
+	if i == -1 {
+		return false
+	}
+
+	return helper.InArray(email[i+1:], u.provisioning.LinkEmailDomains)
+}
+
+// uniqueUsername appends a numeric suffix until the username is free
+func (u UserUsecase) uniqueUsername(ctx context.Context, username string) (string, error) {
+	candidate := username
+	for i := 2; i < 100; i++ {
+		exists, err := u.userRepo.UsernameExists(ctx, candidate)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s%d", username, i)
+	}
+
+	return "", errors.ErrConflict
+}
+
+func emailOrNil(email string) *string {
+	if email == "" {
+		return nil
+	}
+	return &email
 }
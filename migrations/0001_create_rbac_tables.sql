@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS rbac_role (
+    id          SERIAL PRIMARY KEY,
+    name        VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT
+);
+
+CREATE TABLE IF NOT EXISTS rbac_permission (
+    id          SERIAL PRIMARY KEY,
+    code        VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT
+);
+
+CREATE TABLE IF NOT EXISTS rbac_role_permission (
+    role_id       INTEGER NOT NULL REFERENCES rbac_role (id) ON DELETE CASCADE,
+    permission_id INTEGER NOT NULL REFERENCES rbac_permission (id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE TABLE IF NOT EXISTS rbac_user_role (
+    user_id INTEGER NOT NULL REFERENCES auth_user (id) ON DELETE CASCADE,
+    role_id INTEGER NOT NULL REFERENCES rbac_role (id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, role_id)
+);
+
+INSERT INTO rbac_permission (code, description) VALUES
+    ('users:read', 'List and view users'),
+    ('users:write', 'Create, update and delete users')
+ON CONFLICT (code) DO NOTHING;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS user_identity (
+    id            SERIAL PRIMARY KEY,
+    user_id       INTEGER NOT NULL REFERENCES auth_user (id) ON DELETE CASCADE,
+    provider      VARCHAR(100) NOT NULL,
+    subject       VARCHAR(255) NOT NULL,
+    email         VARCHAR(254),
+    created_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_login_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS user_identity_user_id_idx ON user_identity (user_id);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS kv_store (
+    key        VARCHAR(255) PRIMARY KEY,
+    value      TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS kv_store_expires_at_idx ON kv_store (expires_at);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS api_key (
+    id           SERIAL PRIMARY KEY,
+    name         VARCHAR(100) NOT NULL,
+    owner_id     INTEGER NOT NULL REFERENCES auth_user (id) ON DELETE CASCADE,
+    prefix       VARCHAR(16) NOT NULL,
+    key_hash     CHAR(64) NOT NULL UNIQUE,
+    scopes       TEXT NOT NULL DEFAULT '',
+    expires_at   TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at   TIMESTAMP WITH TIME ZONE,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS api_key_owner_id_idx ON api_key (owner_id);
+
+INSERT INTO rbac_permission (code, description) VALUES
+    ('apikeys:read', 'List API keys'),
+    ('apikeys:write', 'Create, rotate and revoke API keys')
+ON CONFLICT (code) DO NOTHING;
@@ -0,0 +1,3 @@
+INSERT INTO rbac_permission (code, description) VALUES
+    ('sessions:revoke', 'Force-logout other users from all sessions')
+ON CONFLICT (code) DO NOTHING;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS auth_audit_log (
+    id          BIGSERIAL PRIMARY KEY,
+    event_type  VARCHAR(50) NOT NULL,
+    provider    VARCHAR(100) NOT NULL DEFAULT '',
+    subject     VARCHAR(255) NOT NULL DEFAULT '',
+    email       VARCHAR(254) NOT NULL DEFAULT '',
+    ip          VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent  TEXT NOT NULL DEFAULT '',
+    outcome     VARCHAR(20) NOT NULL,
+    reason      TEXT,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS auth_audit_log_created_at_idx ON auth_audit_log (created_at DESC);
+CREATE INDEX IF NOT EXISTS auth_audit_log_subject_idx ON auth_audit_log (subject);
+
+INSERT INTO rbac_permission (code, description) VALUES
+    ('audit:read', 'View the authentication audit log')
+ON CONFLICT (code) DO NOTHING;
@@ -0,0 +1,9 @@
+ALTER TABLE auth_audit_log
+    ADD COLUMN IF NOT EXISTS actor VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS detail TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS auth_audit_log_actor_idx ON auth_audit_log (actor) WHERE actor <> '';
+
+INSERT INTO rbac_permission (code, description) VALUES
+    ('users:impersonate', 'Act as another user for support, every request is audited')
+ON CONFLICT (code) DO NOTHING;
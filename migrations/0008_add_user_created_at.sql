@@ -0,0 +1,25 @@
+ALTER TABLE auth_user
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMP WITH TIME ZONE;
+
+-- Existing users keep their join date instead of the time this migration ran
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'auth_user' AND column_name = 'date_joined'
+    ) THEN
+        UPDATE auth_user SET created_at = date_joined WHERE created_at IS NULL;
+    END IF;
+END $$;
+
+UPDATE auth_user SET created_at = (
+    SELECT MIN(user_identity.created_at) FROM user_identity WHERE user_identity.user_id = auth_user.id
+) WHERE created_at IS NULL;
+
+UPDATE auth_user SET created_at = NOW() WHERE created_at IS NULL;
+
+ALTER TABLE auth_user
+    ALTER COLUMN created_at SET DEFAULT NOW(),
+    ALTER COLUMN created_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS auth_user_created_at_idx ON auth_user (created_at);
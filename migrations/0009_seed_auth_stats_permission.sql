@@ -0,0 +1,3 @@
+INSERT INTO rbac_permission (code, description) VALUES
+    ('auth:stats', 'View token validation cache statistics')
+ON CONFLICT (code) DO NOTHING;
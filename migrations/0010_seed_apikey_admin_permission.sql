@@ -0,0 +1,3 @@
+INSERT INTO rbac_permission (code, description) VALUES
+    ('apikeys:admin', 'List, create, rotate and revoke API keys owned by other users')
+ON CONFLICT (code) DO NOTHING;
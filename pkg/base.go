@@ -1,10 +1,14 @@
 package pkg
 
 import (
+	"djiroutine-go-clean-architecture/pkg/errors"
 	"math"
 	"net/http"
 )
 
+// ProblemContentType is the media type of RFC 7807 error responses
+const ProblemContentType = "application/problem+json"
+
 type Response struct {
 	Code    int         `json:"code"`
 	Status  string      `json:"status"`
@@ -17,6 +21,18 @@ type ResponseWithPaginator struct {
 	Paginator interface{} `json:"paginator"`
 }
 
+// Problem is an RFC 7807 error response, sent to clients that accept application/problem+json
+type Problem struct {
+	Type     string              `json:"type"`
+	Title    string              `json:"title"`
+	Status   int                 `json:"status"`
+	Detail   string              `json:"detail,omitempty"`
+	Instance string              `json:"instance,omitempty"`
+	Code     string              `json:"code"`
+	ErrorID  string              `json:"error_id,omitempty"`
+	Errors   []errors.FieldError `json:"errors,omitempty"`
+}
+
 type Paginator struct {
 	CurrentPage  int32 `json:"current_page"`
 	PerPage      int32 `json:"limit_per_page"`
@@ -69,6 +85,11 @@ func (r *Response) MappingResponseSuccess(message string, data interface{}) {
 	r.Data = data
 }
 
+func (r *Response) MappingResponseCreated(message string, data interface{}) {
+	r.MappingResponseSuccess(message, data)
+	r.Code = http.StatusCreated
+}
+
 func (r *ResponseWithPaginator) MappingPagination(page, limit int32, totalAllRecords, countData int, response Response) {
 	paginator := new(Paginator)
 
@@ -142,3 +163,14 @@ type MaxMinNumberValidation struct {
 	ValueMaxNumber float64 `json:"value_max_number"`
 	ValueMinNumber float64 `json:"value_min_number"`
 }
+
+func (p *Problem) MappingProblem(status int, code, detail, instance, errorID string, fields []errors.FieldError) {
+	p.Type = "about:blank"
+	p.Title = http.StatusText(status)
+	p.Status = status
+	p.Detail = detail
+	p.Instance = instance
+	p.Code = code
+	p.ErrorID = errorID
+	p.Errors = fields
+}
@@ -48,7 +48,8 @@ func NewDBService(ctx context.Context, config DBConfig) (DBService, error) {
 	defer cancel()
 
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Warn),
+		Logger:         logger.Default.LogMode(logger.Warn),
+		TranslateError: true,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("❌ gagal koneksi ke database: %w", err)
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func NewRedisClient(ctx context.Context, redisURL string) (*redis.Client, error) {
+	opt, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("❌ gagal parsing Redis URL: %w", err)
+	}
+
+	client := redis.NewClient(opt)
+
+	// Cek koneksi dengan Ping()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("❌ gagal ping Redis: %w", err)
+	}
+
+	log.Println("✅ Redis terkoneksi dengan sukses!")
+	return client, nil
+}
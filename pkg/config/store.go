@@ -0,0 +1,33 @@
+package config
+
+import (
+	"context"
+	"djiroutine-go-clean-architecture/pkg/store"
+	"fmt"
+	"log"
+)
+
+// NewStore membuat penyimpanan state/verifier sesuai driver (redis, memory, postgres).
+// Driver kosong berarti redis. Koneksi Redis hanya dibuat jika driver-nya redis.
+func NewStore(ctx context.Context, driver, redisURL string, db DBService) (store.Store, error) {
+	switch driver {
+	case "", store.DriverRedis:
+		client, err := NewRedisClient(ctx, redisURL)
+		if err != nil {
+			return nil, err
+		}
+		return store.NewRedisStore(client), nil
+
+	case store.DriverMemory:
+		log.Println("⚠️ Store memory aktif: state dan sesi tidak dibagi antar instance")
+		return store.NewMemoryStore(), nil
+
+	case store.DriverPostgres:
+		if db == nil {
+			return nil, fmt.Errorf("❌ store postgres membutuhkan koneksi database")
+		}
+		return store.NewPostgresStore(db.GetConnection()), nil
+	}
+
+	return nil, fmt.Errorf("❌ driver store tidak dikenal: %s", driver)
+}
@@ -1,12 +1,21 @@
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // AppError adalah struktur dasar untuk error aplikasi
 type AppError struct {
+	// Code adalah status HTTP
 	Code    int
 	Message string
 	Err     error
+
+	// ErrorCode adalah kode stabil yang bisa dibaca mesin, lihat konstanta Code* di codes.go
+	ErrorCode string
+	// Fields berisi error per field untuk error validasi
+	Fields []FieldError
 }
 
 func (e *AppError) Error() string {
@@ -16,29 +25,85 @@ func (e *AppError) Error() string {
 	return e.Message
 }
 
+// Unwrap mengembalikan error asal agar errors.Is dan errors.As tetap bekerja
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// WithCode mengganti kode stabil error, misalnya untuk membedakan jenis error 401
+func (e *AppError) WithCode(code string) *AppError {
+	e.ErrorCode = code
+	return e
+}
+
+// WithFields menambahkan error per field
+func (e *AppError) WithFields(fields ...FieldError) *AppError {
+	e.Fields = append(e.Fields, fields...)
+	return e
+}
+
 // AuthError merepresentasikan error autentikasi
 func AuthError(message string, err error) *AppError {
 	return &AppError{
-		Code:    401,
-		Message: message,
-		Err:     err,
+		Code:      401,
+		Message:   message,
+		Err:       err,
+		ErrorCode: CodeUnauthorized,
 	}
 }
 
 // BadRequestError merepresentasikan error permintaan yang tidak valid
 func BadRequestError(message string, err error) *AppError {
 	return &AppError{
-		Code:    400,
-		Message: message,
-		Err:     err,
+		Code:      400,
+		Message:   message,
+		Err:       err,
+		ErrorCode: CodeBadRequest,
 	}
 }
 
 // InternalServerError merepresentasikan error server internal
 func InternalServerError(message string, err error) *AppError {
 	return &AppError{
-		Code:    500,
-		Message: message,
-		Err:     err,
+		Code:      500,
+		Message:   message,
+		Err:       err,
+		ErrorCode: CodeInternal,
+	}
+}
+
+// ForbiddenError merepresentasikan error akses yang ditolak
+func ForbiddenError(message string, err error) *AppError {
+	return &AppError{
+		Code:      403,
+		Message:   message,
+		Err:       err,
+		ErrorCode: CodeForbidden,
+	}
+}
+
+// NotFoundError merepresentasikan error data yang tidak ditemukan
+func NotFoundError(message string, err error) *AppError {
+	return &AppError{
+		Code:      404,
+		Message:   message,
+		Err:       err,
+		ErrorCode: CodeNotFound,
+	}
+}
+
+// ValidationError menggabungkan beberapa error field menjadi satu error 400, sehingga klien
+// menerima semua field yang salah sekaligus
+func ValidationError(fields ...FieldError) *AppError {
+	messages := make([]string, 0, len(fields))
+	for _, field := range fields {
+		messages = append(messages, field.Message)
+	}
+
+	return &AppError{
+		Code:      400,
+		Message:   strings.Join(messages, ", "),
+		ErrorCode: CodeValidationFailed,
+		Fields:    fields,
 	}
 }
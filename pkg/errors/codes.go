@@ -0,0 +1,127 @@
+package errors
+
+import (
+	"errors"
+	"strings"
+)
+
+// Kode error yang stabil dan bisa dibaca mesin. Nilainya tidak boleh diubah karena dipakai
+// klien untuk menentukan perilaku; pesan error boleh berubah kapan saja.
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidationFailed = "validation_failed"
+	CodeRequired         = "required"
+	CodeInvalidValue     = "invalid_value"
+	CodeInvalidDataType  = "invalid_data_type"
+	CodeOutOfRange       = "out_of_range"
+	CodeInvalidFormat    = "invalid_format"
+	CodeUnauthorized     = "unauthorized"
+	CodeInvalidToken     = "invalid_token"
+	CodeTokenExpired     = "token_expired"
+	CodeTokenRevoked     = "token_revoked"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeInternal         = "internal_error"
+)
+
+// FieldError adalah error validasi pada satu field request
+type FieldError struct {
+	Field string `json:"field"`
+	Code  string `json:"code"`
+	// Rule adalah aturan validasi yang gagal, misalnya "min_length", untuk memilih pesan
+	// di katalog jika satu kode dipakai beberapa aturan
+	Rule string `json:"rule,omitempty"`
+	// Param adalah parameter aturan yang gagal, misalnya batas min/max atau pilihan oneof
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// sentinelCodes memetakan sentinel error di error.go ke kodenya
+var sentinelCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrInternalServerError, CodeInternal},
+	{ErrNotFound, CodeNotFound},
+	{ErrUnAuthorize, CodeUnauthorized},
+	{ErrConflict, CodeConflict},
+	{ErrBadParamInput, CodeBadRequest},
+	{ErrPublicKey, CodeInvalidToken},
+	{ErrInvalidDataType, CodeInvalidDataType},
+	{ErrIsRequired, CodeRequired},
+	{ErrInvalidValue, CodeInvalidValue},
+	{ErrForbidden, CodeForbidden},
+	{ErrInvalidToken, CodeInvalidToken},
+	{ErrInvalidTokenType, CodeInvalidToken},
+	{ErrNotMatchTokenCredentials, CodeInvalidToken},
+	{ErrInvalidTokenCredentials, CodeInvalidToken},
+	{ErrInvalidTokenExpired, CodeTokenExpired},
+}
+
+// Code mengembalikan kode stabil dari err: ErrorCode milik AppError, kode sentinel error,
+// atau CodeInternal untuk error yang tidak dikenal
+func Code(err error) string {
+	var appErr *AppError
+	if errors.As(err, &appErr) && appErr.ErrorCode != "" {
+		return appErr.ErrorCode
+	}
+
+	for _, sentinel := range sentinelCodes {
+		if errors.Is(err, sentinel.err) {
+			return sentinel.code
+		}
+	}
+
+	if appErr != nil {
+		return CodeForStatus(appErr.Code)
+	}
+
+	return CodeInternal
+}
+
+// CodeForStatus mengembalikan kode umum untuk status HTTP
+func CodeForStatus(status int) string {
+	switch {
+	case status == 400:
+		return CodeBadRequest
+	case status == 401:
+		return CodeUnauthorized
+	case status == 403:
+		return CodeForbidden
+	case status == 404:
+		return CodeNotFound
+	case status == 409:
+		return CodeConflict
+	case status >= 500:
+		return CodeInternal
+	default:
+		return CodeBadRequest
+	}
+}
+
+// NewFieldError membuat FieldError dengan pesan dari katalog dalam DefaultLanguage; pesannya
+// diterjemahkan ulang per request oleh HTTP error handler
+func NewFieldError(field, code, rule, param string) FieldError {
+	fieldErr := FieldError{Field: field, Code: code, Rule: rule, Param: param}
+	fieldErr.Message = FieldMessage(DefaultLanguage(), fieldErr)
+	return fieldErr
+}
+
+// FieldErr membuat error 400 untuk satu field, misalnya FieldErr("id", ErrInvalidDataType).
+// Pesannya diambil dari katalog dalam DefaultLanguage, misalnya "ID has an invalid data type".
+func FieldErr(field string, err error) *AppError {
+	return ValidationError(NewFieldError(field, Code(err), "", ""))
+}
+
+// ParseFieldMessage mengubah pesan validasi lama berformat "{field} {sentinel error}",
+// misalnya dari helper.GlobalValidationQueryParams, menjadi error 400 dengan FieldError
+func ParseFieldMessage(message string) *AppError {
+	for _, sentinel := range []error{ErrIsRequired, ErrInvalidValue, ErrInvalidDataType} {
+		if field := strings.TrimSuffix(message, " "+sentinel.Error()); field != message && field != "" {
+			return FieldErr(field, sentinel)
+		}
+	}
+
+	return BadRequestError(message, nil)
+}
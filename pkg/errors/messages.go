@@ -0,0 +1,289 @@
+package errors
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Bahasa yang tersedia di katalog pesan
+const (
+	LanguageEnglish    = "en"
+	LanguageIndonesian = "id"
+)
+
+// messages adalah katalog pesan per bahasa, dengan key kode error atau "kode.rule" untuk
+// membedakan aturan validasi dengan kode yang sama. {field} diganti label field dan
+// {param} diganti FieldError.Param.
+var messages = map[string]map[string]string{
+	LanguageEnglish: {
+		CodeBadRequest:       "The request is invalid",
+		CodeValidationFailed: "The request contains invalid fields",
+		CodeUnauthorized:     "Authentication is required",
+		CodeInvalidToken:     "The authorization token is invalid",
+		CodeTokenExpired:     "The authorization token has expired",
+		CodeTokenRevoked:     "The authorization token has been revoked",
+		CodeForbidden:        "You don't have permission to access this resource",
+		CodeNotFound:         "The requested item was not found",
+		CodeConflict:         "The item already exists",
+		CodeInternal:         "Internal server error",
+
+		CodeRequired:                   "{field} is required",
+		CodeInvalidValue:               "{field} has an invalid value",
+		CodeInvalidValue + ".oneof":    "{field} must be one of {param}",
+		CodeInvalidDataType:            "{field} has an invalid data type",
+		CodeInvalidFormat:              "{field} has an invalid format",
+		CodeInvalidFormat + ".email":   "{field} must be a valid email address",
+		CodeInvalidFormat + ".date":    "{field} must be a date in the format {param}",
+		CodeOutOfRange:                 "{field} is out of range",
+		CodeOutOfRange + ".min":        "{field} must be at least {param}",
+		CodeOutOfRange + ".max":        "{field} must be at most {param}",
+		CodeOutOfRange + ".min_length": "{field} must be at least {param} characters",
+		CodeOutOfRange + ".max_length": "{field} must be at most {param} characters",
+		CodeOutOfRange + ".min_items":  "{field} must contain at least {param} items",
+		CodeOutOfRange + ".max_items":  "{field} must contain at most {param} items",
+		CodeOutOfRange + ".between":    "{field} must be between -{param} and {param}",
+	},
+	LanguageIndonesian: {
+		CodeBadRequest:       "Permintaan tidak valid",
+		CodeValidationFailed: "Permintaan berisi field yang tidak valid",
+		CodeUnauthorized:     "Autentikasi diperlukan",
+		CodeInvalidToken:     "Token otorisasi tidak valid",
+		CodeTokenExpired:     "Token otorisasi sudah kedaluwarsa",
+		CodeTokenRevoked:     "Token otorisasi sudah dicabut",
+		CodeForbidden:        "Anda tidak memiliki izin untuk mengakses resource ini",
+		CodeNotFound:         "Data yang diminta tidak ditemukan",
+		CodeConflict:         "Data sudah ada",
+		CodeInternal:         "Terjadi kesalahan pada server",
+
+		CodeRequired:                   "{field} wajib diisi",
+		CodeInvalidValue:               "{field} tidak valid",
+		CodeInvalidValue + ".oneof":    "{field} harus salah satu dari {param}",
+		CodeInvalidDataType:            "Tipe data {field} tidak valid",
+		CodeInvalidFormat:              "Format {field} tidak valid",
+		CodeInvalidFormat + ".email":   "{field} harus berupa alamat email yang valid",
+		CodeInvalidFormat + ".date":    "{field} harus berupa tanggal dengan format {param}",
+		CodeOutOfRange:                 "{field} di luar rentang yang diizinkan",
+		CodeOutOfRange + ".min":        "{field} minimal {param}",
+		CodeOutOfRange + ".max":        "{field} maksimal {param}",
+		CodeOutOfRange + ".min_length": "{field} minimal {param} karakter",
+		CodeOutOfRange + ".max_length": "{field} maksimal {param} karakter",
+		CodeOutOfRange + ".min_items":  "{field} minimal berisi {param} item",
+		CodeOutOfRange + ".max_items":  "{field} maksimal berisi {param} item",
+		CodeOutOfRange + ".between":    "{field} harus di antara -{param} dan {param}",
+	},
+}
+
+// translations adalah terjemahan pesan spesifik yang ditulis handler dalam bahasa Inggris,
+// dengan key pesan aslinya. Pesan yang tidak ada di sini dikirim apa adanya dalam bahasa
+// Inggris agar klien tetap tahu penyebab error-nya.
+var translations = map[string]map[string]string{
+	LanguageIndonesian: {
+		"Unauthorized":                                       "Autentikasi diperlukan",
+		"Invalid or expired token":                           "Token tidak valid atau sudah kedaluwarsa",
+		"Token has been revoked":                             "Token sudah dicabut",
+		"Invalid or missing CSRF token":                      "Token CSRF tidak valid atau tidak ada",
+		"Authorization header is required":                   "Header Authorization wajib diisi",
+		"Authorization header format must be Bearer {token}": "Format header Authorization harus Bearer {token}",
+
+		"Missing code or state parameter":                                  "Parameter code atau state tidak ada",
+		"State cookie is missing, start the login again from this browser": "Cookie state tidak ada, mulai login lagi dari browser ini",
+		"State is expired or unknown, start the login again":               "State sudah kedaluwarsa atau tidak dikenal, mulai login lagi",
+		"State has already been used":                                      "State sudah pernah dipakai",
+		"State does not match the browser that started the login":          "State tidak cocok dengan browser yang memulai login",
+		"State was issued for another login provider":                      "State diterbitkan untuk provider login lain",
+		"redirect_to is not allowed":                                       "redirect_to tidak diizinkan",
+		"Unknown login provider":                                           "Provider login tidak dikenal",
+		"Failed to get access token":                                       "Gagal mendapatkan access token",
+		"Refresh token is invalid, expired or already used":                "Refresh token tidak valid, sudah kedaluwarsa atau sudah dipakai",
+
+		"Session not found or expired": "Sesi tidak ditemukan atau sudah kedaluwarsa",
+		"Session expired":              "Sesi sudah kedaluwarsa",
+		"Session has been revoked":     "Sesi sudah dicabut",
+		"sub is required":              "sub wajib diisi",
+
+		"User not found":                                                               "User tidak ditemukan",
+		"You cannot impersonate yourself":                                              "Anda tidak bisa meng-impersonasi diri sendiri",
+		"API keys cannot impersonate users":                                            "API key tidak bisa meng-impersonasi user",
+		"Impersonation cannot be started from an impersonated session":                 "Impersonasi tidak bisa dimulai dari sesi impersonasi",
+		"Impersonation token is invalid or expired":                                    "Token impersonasi tidak valid atau sudah kedaluwarsa",
+		"Impersonation has been revoked":                                               "Impersonasi sudah dicabut",
+		"Impersonated sessions cannot log the user out, end the impersonation instead": "Sesi impersonasi tidak bisa me-logout user, akhiri impersonasinya",
+
+		"API keys have no sessions, revoke the key instead": "API key tidak memiliki sesi, cabut key-nya",
+		"Invalid API key":                            "API key tidak valid",
+		"API key is expired or revoked":              "API key sudah kedaluwarsa atau dicabut",
+		"You cannot create API keys for other users": "Anda tidak bisa membuat API key untuk user lain",
+	},
+}
+
+// fieldLabels adalah nama field yang ditampilkan di pesan per bahasa. Field yang tidak ada
+// di sini ditampilkan apa adanya dengan "_" diganti spasi.
+var fieldLabels = map[string]map[string]string{
+	LanguageEnglish: {
+		"id":              "ID",
+		"user_id":         "user ID",
+		"owner_id":        "owner ID",
+		"expires_at":      "expiry time",
+		"id__in":          "IDs",
+		"created_at__gte": "created from",
+		"created_at__lte": "created until",
+	},
+	LanguageIndonesian: {
+		"id":              "ID",
+		"page":            "halaman",
+		"limit":           "batas",
+		"search":          "pencarian",
+		"name":            "nama",
+		"username":        "nama pengguna",
+		"first_name":      "nama depan",
+		"last_name":       "nama belakang",
+		"user_id":         "ID user",
+		"owner_id":        "ID pemilik",
+		"expires_at":      "waktu kedaluwarsa",
+		"reason":          "alasan",
+		"outcome":         "hasil",
+		"from":            "tanggal awal",
+		"to":              "tanggal akhir",
+		"scopes":          "scope",
+		"sort":            "urutan",
+		"id__in":          "daftar ID",
+		"created_at__gte": "tanggal dibuat awal",
+		"created_at__lte": "tanggal dibuat akhir",
+	},
+}
+
+var (
+	defaultLanguage   = LanguageEnglish
+	defaultLanguageMu sync.RWMutex
+)
+
+// SetDefaultLanguage mengganti bahasa yang dipakai jika klien tidak mengirim Accept-Language
+// yang didukung. Bahasa yang tidak ada di katalog diabaikan dan mengembalikan false.
+func SetDefaultLanguage(lang string) bool {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if _, ok := messages[lang]; !ok {
+		return false
+	}
+
+	defaultLanguageMu.Lock()
+	defaultLanguage = lang
+	defaultLanguageMu.Unlock()
+	return true
+}
+
+// DefaultLanguage mengembalikan bahasa bawaan katalog pesan
+func DefaultLanguage() string {
+	defaultLanguageMu.RLock()
+	defer defaultLanguageMu.RUnlock()
+	return defaultLanguage
+}
+
+// NegotiateLanguage memilih bahasa dari header Accept-Language, misalnya "id-ID,id;q=0.9,en;q=0.8",
+// berdasarkan nilai q tertinggi. Jika tidak ada yang didukung, DefaultLanguage yang dipakai.
+func NegotiateLanguage(acceptLanguage string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, q := part, 1.0
+		if i := strings.Index(part, ";"); i != -1 {
+			tag = part[:i]
+			if value := strings.TrimSpace(part[i+1:]); strings.HasPrefix(value, "q=") {
+				parsed, err := strconv.ParseFloat(value[2:], 64)
+				if err != nil {
+					continue
+				}
+				q = parsed
+			}
+		}
+
+		lang := strings.ToLower(strings.TrimSpace(tag))
+		if i := strings.Index(lang, "-"); i != -1 {
+			lang = lang[:i]
+		}
+		if _, ok := messages[lang]; ok && q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+
+	if best == "" {
+		return DefaultLanguage()
+	}
+	return best
+}
+
+// Message mengembalikan pesan umum untuk kode error dalam bahasa lang, kosong jika kode
+// tidak ada di katalog
+func Message(lang, code string) string {
+	return lookup(lang, code)
+}
+
+// Translate mengembalikan terjemahan message dalam bahasa lang, atau message apa adanya jika
+// tidak ada terjemahannya
+func Translate(lang, message string) string {
+	if translated, ok := translations[lang][message]; ok {
+		return translated
+	}
+	return message
+}
+
+// FieldMessage menyusun pesan FieldError dalam bahasa lang dari katalog, dengan label field
+// dan FieldError.Param. Kode yang tidak ada di katalog mengembalikan FieldError.Message.
+func FieldMessage(lang string, field FieldError) string {
+	template := ""
+	if field.Rule != "" {
+		template = lookup(lang, field.Code+"."+field.Rule)
+	}
+	if template == "" {
+		template = lookup(lang, field.Code)
+	}
+	if template == "" {
+		return field.Message
+	}
+
+	message := strings.NewReplacer("{field}", FieldLabel(lang, field.Field), "{param}", field.Param).Replace(template)
+	return capitalize(message)
+}
+
+// LocalizeFields mengembalikan salinan fields dengan Message dalam bahasa lang
+func LocalizeFields(lang string, fields []FieldError) []FieldError {
+	if len(fields) == 0 {
+		return fields
+	}
+
+	localized := make([]FieldError, len(fields))
+	for i, field := range fields {
+		field.Message = FieldMessage(lang, field)
+		localized[i] = field
+	}
+	return localized
+}
+
+// FieldLabel mengembalikan nama field yang ditampilkan di pesan dalam bahasa lang
+func FieldLabel(lang, field string) string {
+	key := strings.ToLower(field)
+	if label, ok := fieldLabels[lang][key]; ok {
+		return label
+	}
+	// Field bertingkat seperti "items[0].code" ditampilkan apa adanya
+	if strings.ContainsAny(field, ".[") {
+		return field
+	}
+	return strings.ReplaceAll(key, "_", " ")
+}
+
+func lookup(lang, key string) string {
+	if message, ok := messages[lang][key]; ok {
+		return message
+	}
+	return messages[LanguageEnglish][key]
+}
+
+func capitalize(message string) string {
+	r, size := utf8.DecodeRuneInString(message)
+	if r == utf8.RuneError {
+		return message
+	}
+	return string(unicode.ToUpper(r)) + message[size:]
+}
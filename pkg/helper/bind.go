@@ -0,0 +1,237 @@
+package helper
+
+import (
+	"djiroutine-go-clean-architecture/pkg/errors"
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Struct tags read by Bind. A field is bound from the source named by its tag, so a request
+// struct states where every value comes from:
+//
+//	type Request struct {
+//		ID    int      `param:"id"`
+//		Page  *int     `query:"page" default:"1" validate:"min=1"`
+//		Tags  []string `query:"tag"`
+//		Trace string   `header:"X-Request-ID"`
+//		Name  string   `json:"name" validate:"required"`
+//	}
+const (
+	BindTagParam   = "param"
+	BindTagQuery   = "query"
+	BindTagHeader  = "header"
+	BindTagDefault = "default"
+)
+
+// Bind decodes a T from the JSON body, query string, headers and path parameters of c, in that
+// order so later sources win, fills fields that are still zero from their default tag and
+// validates the result with ValidateRequest. Every malformed or invalid field is returned at
+// once as a 400 *errors.AppError.
+func Bind[T any](c echo.Context) (*T, error) {
+	request := new(T)
+
+	value := reflect.ValueOf(request).Elem()
+	if value.Kind() != reflect.Struct {
+		return nil, errors.InternalServerError("Bind target must be a struct", nil)
+	}
+
+	fields, err := bindBody(c, request)
+	if err != nil {
+		return nil, err
+	}
+	bindValues(c, value, false, &fields)
+	if err := bindValues(c, value, true, &fields); err != nil {
+		return nil, errors.InternalServerError("Invalid request defaults", err)
+	}
+
+	if err := validateRequest(request, fields); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// bindBody decodes the JSON body into request; an empty body is not an error. A value of the
+// wrong type is returned as a field error so the other sources are still bound and validated.
+func bindBody(c echo.Context, request interface{}) ([]errors.FieldError, error) {
+	if c.Request().Body == nil || c.Request().ContentLength == 0 {
+		return nil, nil
+	}
+
+	dec := json.NewDecoder(c.Request().Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(request)
+	if err == nil || stderrors.Is(err, io.EOF) {
+		return nil, nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if stderrors.As(err, &typeErr) && typeErr.Field != "" {
+		return errors.FieldErr(typeErr.Field, errors.ErrInvalidDataType).Fields, nil
+	}
+	return nil, errors.BadRequestError("Invalid JSON body: "+err.Error(), err)
+}
+
+// bindSource returns the name of field and its values in the request source its tag points to
+func bindSource(c echo.Context, field reflect.StructField) (string, []string, bool) {
+	if name := tagName(field, BindTagParam); name != "" {
+		for i, paramName := range c.ParamNames() {
+			if paramName == name && i < len(c.ParamValues()) {
+				return name, []string{c.ParamValues()[i]}, true
+			}
+		}
+		return name, nil, false
+	}
+
+	if name := tagName(field, BindTagQuery); name != "" {
+		values, ok := c.QueryParams()[name]
+		return name, values, ok
+	}
+
+	if name := tagName(field, BindTagHeader); name != "" {
+		values, ok := c.Request().Header[http.CanonicalHeaderKey(name)]
+		return name, values, ok
+	}
+
+	return "", nil, false
+}
+
+// bindValues sets the fields of value from the request sources, or with defaults set, the
+// fields that are still zero from their default tag. Embedded structs are bound as well.
+// Only a default tag that does not convert to its field returns an error.
+func bindValues(c echo.Context, value reflect.Value, defaults bool, fields *[]errors.FieldError) error {
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		fieldValue := value.Field(i)
+
+		if field.Anonymous && fieldValue.Kind() == reflect.Struct {
+			if err := bindValues(c, fieldValue, defaults, fields); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if defaults {
+			if def, ok := field.Tag.Lookup(BindTagDefault); ok && fieldValue.IsZero() {
+				if err := setValue(fieldValue, []string{def}); err != nil {
+					return stderrors.New("helper: invalid default tag on " + typ.Name() + "." + field.Name + ": " + err.Error())
+				}
+			}
+			continue
+		}
+
+		// An empty parameter such as "?page=" counts as not sent so the default still applies
+		name, values, ok := bindSource(c, field)
+		if !ok || len(values) == 0 || (len(values) == 1 && values[0] == "") {
+			continue
+		}
+		if err := setValue(fieldValue, values); err != nil {
+			*fields = append(*fields, errors.FieldErr(name, errors.ErrInvalidDataType).Fields...)
+		}
+	}
+
+	return nil
+}
+
+func tagName(field reflect.StructField, tag string) string {
+	name := strings.Split(field.Tag.Get(tag), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// setValue converts values to the type of value. Slices take every value split on commas,
+// so "?id=1,2&id=3" gives [1 2 3]; other types take the first value.
+func setValue(value reflect.Value, values []string) error {
+	if value.Kind() == reflect.Ptr {
+		elem := reflect.New(value.Type().Elem())
+		if err := setValue(elem.Elem(), values); err != nil {
+			return err
+		}
+		value.Set(elem)
+		return nil
+	}
+
+	if value.Kind() == reflect.Slice && value.Type().Elem().Kind() != reflect.Uint8 {
+		var items []string
+		for _, v := range values {
+			items = append(items, strings.Split(v, ",")...)
+		}
+		slice := reflect.MakeSlice(value.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setValue(slice.Index(i), []string{strings.TrimSpace(item)}); err != nil {
+				return err
+			}
+		}
+		value.Set(slice)
+		return nil
+	}
+
+	return setScalar(value, values[0])
+}
+
+func setScalar(value reflect.Value, raw string) error {
+	if value.Type() == reflect.TypeOf(time.Time{}) {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			if parsed, err = time.Parse(DateFormatDefault, raw); err != nil {
+				return err
+			}
+		}
+		value.Set(reflect.ValueOf(parsed))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		value.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value.Type() == reflect.TypeOf(time.Duration(0)) {
+			parsed, err := time.ParseDuration(raw)
+			if err != nil {
+				return err
+			}
+			value.SetInt(int64(parsed))
+			return nil
+		}
+		parsed, err := strconv.ParseInt(raw, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(raw, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(raw, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(parsed)
+	default:
+		return stderrors.New("unsupported type " + value.Type().String())
+	}
+	return nil
+}
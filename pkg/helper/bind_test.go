@@ -0,0 +1,176 @@
+package helper_test
+
+import (
+	"djiroutine-go-clean-architecture/pkg/errors"
+	"djiroutine-go-clean-architecture/pkg/helper"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type pagination struct {
+	Page  *int `query:"page" default:"1" validate:"min=1"`
+	Limit int  `query:"limit" default:"20" validate:"max=100"`
+}
+
+type bindRequest struct {
+	pagination
+	ID      int           `param:"id"`
+	IDs     []int         `query:"id"`
+	Trace   string        `header:"X-Request-ID"`
+	Since   *time.Time    `query:"since"`
+	Timeout time.Duration `query:"timeout" default:"5s"`
+	Name    string        `json:"name" validate:"required"`
+}
+
+func newContext(method, target, body string) echo.Context {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	req.Header.Set("X-Request-ID", "trace-1")
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues("7")
+	return c
+}
+
+func TestBind(t *testing.T) {
+	since := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	page := func(n int) *int {
+		return &n
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		want   bindRequest
+		fields []string
+	}{
+		{
+			name:   "every source and defaults",
+			target: "/items/7?id=1,2&id=3&since=2024-01-02",
+			body:   `{"name":"a"}`,
+			want: bindRequest{
+				pagination: pagination{Page: page(1), Limit: 20},
+				ID:         7, IDs: []int{1, 2, 3}, Trace: "trace-1", Since: &since, Timeout: 5 * time.Second, Name: "a",
+			},
+		},
+		{
+			name:   "sent values win over defaults",
+			target: "/items/7?page=3&limit=50&timeout=1m",
+			body:   `{"name":"a"}`,
+			want: bindRequest{
+				pagination: pagination{Page: page(3), Limit: 50},
+				ID:         7, Trace: "trace-1", Timeout: time.Minute, Name: "a",
+			},
+		},
+		{
+			name:   "empty parameter keeps the default",
+			target: "/items/7?page=",
+			body:   `{"name":"a"}`,
+			want: bindRequest{
+				pagination: pagination{Page: page(1), Limit: 20},
+				ID:         7, Trace: "trace-1", Timeout: 5 * time.Second, Name: "a",
+			},
+		},
+		{
+			name:   "malformed values and failed rules are reported together",
+			target: "/items/7?page=x&limit=101&since=yesterday",
+			body:   `{"name":""}`,
+			fields: []string{"page", "since", "limit", "name"},
+		},
+		{
+			name:   "wrong JSON type is a field error",
+			target: "/items/7",
+			body:   `{"name":1}`,
+			fields: []string{"name"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := helper.Bind[bindRequest](newContext(http.MethodPost, tt.target, tt.body))
+			if tt.fields != nil {
+				appErr, ok := err.(*errors.AppError)
+				if !ok || appErr.Code != http.StatusBadRequest {
+					t.Fatalf("Bind error = %v, want a 400 *errors.AppError", err)
+				}
+				var fields []string
+				for _, field := range appErr.Fields {
+					fields = append(fields, field.Field)
+				}
+				if !reflect.DeepEqual(fields, tt.fields) {
+					t.Errorf("fields = %v, want %v", fields, tt.fields)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Bind: %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Bind = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindInvalidBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "malformed JSON", body: `{"name":`},
+		{name: "unknown field", body: `{"name":"a","admin":true}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := helper.Bind[bindRequest](newContext(http.MethodPost, "/items/7", tt.body))
+			appErr, ok := err.(*errors.AppError)
+			if !ok || appErr.Code != http.StatusBadRequest {
+				t.Fatalf("Bind error = %v, want a 400 *errors.AppError", err)
+			}
+		})
+	}
+}
+
+func TestBindInvalidTags(t *testing.T) {
+	type badDefault struct {
+		Page int `query:"page" default:"first"`
+	}
+	type badRule struct {
+		Page int `query:"page" validate:"min=first"`
+	}
+
+	tests := []struct {
+		name string
+		bind func(c echo.Context) error
+	}{
+		{name: "default that does not convert", bind: func(c echo.Context) error {
+			_, err := helper.Bind[badDefault](c)
+			return err
+		}},
+		{name: "malformed validate rule", bind: func(c echo.Context) error {
+			_, err := helper.Bind[badRule](c)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.bind(newContext(http.MethodGet, "/items/7", ""))
+			appErr, ok := err.(*errors.AppError)
+			if !ok || appErr.Code != http.StatusInternalServerError {
+				t.Fatalf("Bind error = %v, want a 500 *errors.AppError", err)
+			}
+		})
+	}
+}
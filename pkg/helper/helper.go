@@ -5,8 +5,10 @@ import (
 	"crypto/rand"
 	pkg "djiroutine-go-clean-architecture/pkg"
 	"djiroutine-go-clean-architecture/pkg/errors"
+	"djiroutine-go-clean-architecture/pkg/validator"
 	"encoding/base64"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"log"
 	mathRand "math/rand"
@@ -156,24 +158,42 @@ func GetStatusCode(err error) int {
 	if err == nil {
 		return http.StatusOK
 	}
-	switch err {
-	case errors.ErrInternalServerError:
+
+	var appErr *errors.AppError
+	if stderrors.As(err, &appErr) {
+		return appErr.Code
+	}
+
+	switch {
+	case stderrors.Is(err, errors.ErrInternalServerError):
 		return http.StatusInternalServerError
-	case errors.ErrForbidden:
+	case stderrors.Is(err, errors.ErrForbidden):
 		return http.StatusForbidden
-	case errors.ErrNotFound:
+	case stderrors.Is(err, errors.ErrNotFound):
 		return http.StatusNotFound
-	case errors.ErrUnAuthorize:
+	case stderrors.Is(err, errors.ErrUnAuthorize):
 		return http.StatusUnauthorized
-	case errors.ErrConflict:
+	case stderrors.Is(err, errors.ErrConflict):
 		return http.StatusConflict
-	case errors.ErrBadParamInput:
+	case stderrors.Is(err, errors.ErrBadParamInput),
+		stderrors.Is(err, errors.ErrInvalidDataType),
+		stderrors.Is(err, errors.ErrIsRequired),
+		stderrors.Is(err, errors.ErrInvalidValue):
 		return http.StatusBadRequest
+	case stderrors.Is(err, errors.ErrInvalidToken),
+		stderrors.Is(err, errors.ErrInvalidTokenType),
+		stderrors.Is(err, errors.ErrNotMatchTokenCredentials),
+		stderrors.Is(err, errors.ErrInvalidTokenCredentials),
+		stderrors.Is(err, errors.ErrInvalidTokenExpired):
+		return http.StatusUnauthorized
 	default:
 		return http.StatusInternalServerError
 	}
 }
 
+// JsonDecode decodes the JSON body of c into request, rejecting unknown fields.
+//
+// Deprecated: use Bind, which also binds path, query and header values and validates the result.
 func JsonDecode(c echo.Context, request interface{}) (interface{}, error) {
 	dec := json.NewDecoder(c.Request().Body)
 	dec.DisallowUnknownFields()
@@ -190,6 +210,9 @@ func JsonString(object interface{}) string {
 	return string(res)
 }
 
+// QueryParamDecode decodes the query string of c into request by its schema tags.
+//
+// Deprecated: use Bind, which reads query tags and validates the result.
 func QueryParamDecode(c echo.Context, request interface{}) (interface{}, error) {
 	params := c.QueryParams()
 	if err := mapQueryParams(params, request); err != nil {
@@ -204,6 +227,68 @@ func mapQueryParams(params url.Values, request interface{}) error {
 	return decoder.Decode(request, params)
 }
 
+// GlobalValidationError runs GlobalValidationQueryParams and returns a 400 error carrying the failed field
+func GlobalValidationError(request pkg.GlobalValidation) error {
+	if ok, message := GlobalValidationQueryParams(request); !ok {
+		return errors.ParseFieldMessage(message)
+	}
+	return nil
+}
+
+// globalValidator is implemented by requests that still describe their rules with pkg.GlobalValidation
+type globalValidator interface {
+	MappingToGlobalValidation() pkg.GlobalValidation
+}
+
+// ValidateRequest checks the validate tags of request and, while a request still implements
+// MappingToGlobalValidation, its legacy rules too. Every failed field is returned in a
+// single 400 error; the legacy rules stop at their first failure.
+func ValidateRequest(request interface{}) error {
+	return validateRequest(request, nil)
+}
+
+// validateRequest is ValidateRequest for a request that already failed on fields, such as
+// values Bind could not convert; rules on those fields are not reported a second time
+func validateRequest(request interface{}, fields []errors.FieldError) error {
+	found, err := validator.Fields(request)
+	if err != nil {
+		return errors.InternalServerError("Invalid validation rules", err)
+	}
+	for _, field := range found {
+		if !hasField(fields, field.Field) {
+			fields = append(fields, field)
+		}
+	}
+
+	if legacy, ok := request.(globalValidator); ok {
+		var appErr *errors.AppError
+		if err := GlobalValidationError(legacy.MappingToGlobalValidation()); stderrors.As(err, &appErr) {
+			for _, field := range appErr.Fields {
+				if !hasField(fields, field.Field) {
+					fields = append(fields, field)
+				}
+			}
+			if len(appErr.Fields) == 0 {
+				return err
+			}
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return errors.ValidationError(fields...)
+}
+
+func hasField(fields []errors.FieldError, name string) bool {
+	for _, field := range fields {
+		if field.Field == name {
+			return true
+		}
+	}
+	return false
+}
+
 func GlobalValidationQueryParams(request pkg.GlobalValidation) (bool, string) {
 	checkQueryparams, message := ValidationQueryParamsRequired(request.RequiredValidation)
 	if checkQueryparams == false {
@@ -722,6 +807,9 @@ func JSONEncode(data interface{}) string {
 	return string(jsonResult)
 }
 
+// JSONDecode decodes the JSON body of c into request, rejecting unknown fields.
+//
+// Deprecated: use Bind, which also binds path, query and header values and validates the result.
 func JSONDecode(c echo.Context, request interface{}) (interface{}, error) {
 	dec := json.NewDecoder(c.Request().Body)
 	dec.DisallowUnknownFields()
@@ -794,3 +882,15 @@ func SetHttpCookie(c echo.Context, name, value string, age int) {
 		MaxAge:   age,
 	})
 }
+
+func SetSecureHttpCookie(c echo.Context, name, value string, age int, httpOnly, secure bool, sameSite http.SameSite) {
+	http.SetCookie(c.Response(), &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: httpOnly,
+		Secure:   secure,
+		SameSite: sameSite,
+		MaxAge:   age,
+	})
+}
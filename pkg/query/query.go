@@ -0,0 +1,258 @@
+// Package query builds filtered, sorted and paginated GORM queries from a Spec, so a
+// repository lists a page and counts its total from a single description of the query.
+//
+//	spec := query.Spec{
+//		Filters: []query.Filter{
+//			query.Search(param.Search, "first_name", "last_name"),
+//			query.EqualFold("email", param.Email),
+//			query.In("id", param.IDs),
+//		},
+//		Sort:        sort,
+//		Limit:       limit,
+//		Offset:      offset,
+//		WindowCount: true,
+//	}
+//	res, total, err := query.Find[entity.UserResponse](db.Model(&entity.User{}), spec)
+package query
+
+import (
+	"djiroutine-go-clean-architecture/pkg/errors"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Filter adds conditions to a query. The filters in this package are skipped when their
+// value is nil, an empty string, an empty slice or a zero time, so optional request fields
+// can be passed as they are. Column names are written into the SQL and must never come
+// from the request.
+type Filter func(db *gorm.DB) *gorm.DB
+
+// Sort is one column of the ORDER BY
+type Sort struct {
+	Column string
+	Desc   bool
+}
+
+// ParseSort parses a sort parameter such as "-created_at,username", "-" meaning descending,
+// against allowed, which maps the names clients send to columns. An unknown name returns a
+// 400 error on field listing the allowed names.
+func ParseSort(field, value string, allowed map[string]string) ([]Sort, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var res []Sort
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		column, ok := allowed[strings.TrimLeft(part, "+-")]
+		if !ok {
+			names := make([]string, 0, len(allowed))
+			for name := range allowed {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, errors.ValidationError(errors.NewFieldError(field, errors.CodeInvalidValue, "oneof", strings.Join(names, ", ")))
+		}
+		res = append(res, Sort{Column: column, Desc: strings.HasPrefix(part, "-")})
+	}
+
+	return res, nil
+}
+
+// Spec describes the rows and the page to select
+type Spec struct {
+	Filters []Filter
+	Sort    []Sort
+	// TieBreaker is appended to Sort to keep the order stable between pages when the sort
+	// columns have equal values, typically the primary key
+	TieBreaker string
+	// Limit of 0 selects every row
+	Limit  int
+	Offset int
+	// WindowCount selects the total with COUNT(*) OVER() in the page query instead of a
+	// second COUNT query. The page query then selects every column of the model.
+	WindowCount bool
+}
+
+// totalColumn carries COUNT(*) OVER() in window count queries
+const totalColumn = "query_total"
+
+type windowRow[T any] struct {
+	Item  T     `gorm:"embedded"`
+	Total int64 `gorm:"column:query_total"`
+}
+
+// Find returns the page of T selected by spec and the number of rows matching its filters.
+// db carries the context and the model, e.g. db.WithContext(ctx).Model(&entity.User{}).
+func Find[T any](db *gorm.DB, spec Spec) ([]*T, int64, error) {
+	scopes := make([]func(*gorm.DB) *gorm.DB, len(spec.Filters))
+	for i, filter := range spec.Filters {
+		scopes[i] = filter
+	}
+	base := db.Scopes(scopes...).Session(&gorm.Session{})
+
+	page := base
+	for _, s := range spec.Sort {
+		page = page.Order(clause.OrderByColumn{Column: clause.Column{Name: s.Column}, Desc: s.Desc})
+	}
+	if spec.TieBreaker != "" && !sorted(spec.Sort, spec.TieBreaker) {
+		page = page.Order(clause.OrderByColumn{Column: clause.Column{Name: spec.TieBreaker}})
+	}
+	if spec.Limit > 0 {
+		page = page.Limit(spec.Limit).Offset(spec.Offset)
+	}
+
+	if spec.WindowCount {
+		var rows []windowRow[T]
+		if err := page.Select("*, COUNT(*) OVER() AS " + totalColumn).Find(&rows).Error; err != nil {
+			return nil, 0, err
+		}
+
+		items := make([]*T, len(rows))
+		for i := range rows {
+			items[i] = &rows[i].Item
+		}
+		if len(rows) > 0 || spec.Offset == 0 {
+			var total int64
+			if len(rows) > 0 {
+				total = rows[0].Total
+			}
+			return items, total, nil
+		}
+
+		// A page past the last row has no row to carry the total
+		total, err := count(base)
+		return items, total, err
+	}
+
+	var items []*T
+	if err := page.Find(&items).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// The total is already known when every row fits in the first page
+	if spec.Limit <= 0 || (spec.Offset == 0 && len(items) < spec.Limit) {
+		return items, int64(len(items)), nil
+	}
+
+	total, err := count(base)
+	return items, total, err
+}
+
+func count(db *gorm.DB) (int64, error) {
+	var total int64
+	err := db.Count(&total).Error
+	return total, err
+}
+
+func sorted(sorts []Sort, column string) bool {
+	for _, s := range sorts {
+		if s.Column == column {
+			return true
+		}
+	}
+	return false
+}
+
+// Equal matches column = value
+func Equal(column string, value interface{}) Filter {
+	return where(value, column+" = ?", value)
+}
+
+// EqualFold matches column = value ignoring case
+func EqualFold(column string, value interface{}) Filter {
+	return where(value, "LOWER("+column+") = LOWER(?)", value)
+}
+
+// In matches column IN values, values being a slice
+func In(column string, values interface{}) Filter {
+	return where(values, column+" IN ?", values)
+}
+
+// Gte matches column >= value
+func Gte(column string, value interface{}) Filter {
+	return where(value, column+" >= ?", value)
+}
+
+// Lte matches column <= value
+func Lte(column string, value interface{}) Filter {
+	return where(value, column+" <= ?", value)
+}
+
+// Lt matches column < value
+func Lt(column string, value interface{}) Filter {
+	return where(value, column+" < ?", value)
+}
+
+// Search matches rows where any of columns contains value, ignoring case
+func Search(value *string, columns ...string) Filter {
+	if isEmpty(value) || len(columns) == 0 {
+		return none
+	}
+
+	pattern := "%" + *value + "%"
+	return func(db *gorm.DB) *gorm.DB {
+		condition := db.Session(&gorm.Session{NewDB: true})
+		for i, column := range columns {
+			if i == 0 {
+				condition = condition.Where("LOWER("+column+") LIKE LOWER(?)", pattern)
+			} else {
+				condition = condition.Or("LOWER("+column+") LIKE LOWER(?)", pattern)
+			}
+		}
+		return db.Where(condition)
+	}
+}
+
+func where(value interface{}, condition string, args ...interface{}) Filter {
+	if isEmpty(value) {
+		return none
+	}
+
+	for i, arg := range args {
+		args[i] = deref(arg)
+	}
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(condition, args...)
+	}
+}
+
+func none(db *gorm.DB) *gorm.DB {
+	return db
+}
+
+func isEmpty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return true
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len() == 0
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.IsZero()
+	}
+	return false
+}
+
+func deref(value interface{}) interface{} {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v.Interface()
+}
@@ -0,0 +1,254 @@
+package query_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"djiroutine-go-clean-architecture/pkg/errors"
+	"djiroutine-go-clean-architecture/pkg/query"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestParseSort(t *testing.T) {
+	allowed := map[string]string{
+		"id":         "id",
+		"username":   "username",
+		"created_at": "date_joined",
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		want  []query.Sort
+		// param is the list of allowed names in the error, empty when the value is valid
+		param string
+	}{
+		{name: "empty", value: ""},
+		{name: "blank", value: "  "},
+		{name: "ascending", value: "username", want: []query.Sort{{Column: "username"}}},
+		{name: "explicit ascending", value: "+username", want: []query.Sort{{Column: "username"}}},
+		{name: "descending maps to column", value: "-created_at", want: []query.Sort{{Column: "date_joined", Desc: true}}},
+		{name: "several columns keep their order", value: "-created_at, id", want: []query.Sort{{Column: "date_joined", Desc: true}, {Column: "id"}}},
+		{name: "unknown name", value: "password", param: "created_at, id, username"},
+		{name: "column name is not a sort name", value: "date_joined", param: "created_at, id, username"},
+		{name: "one unknown name rejects the list", value: "id,-password", param: "created_at, id, username"},
+		{name: "empty part", value: "id,", param: "created_at, id, username"},
+		{name: "injection", value: "id;DROP TABLE auth_user", param: "created_at, id, username"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := query.ParseSort("sort", tt.value, allowed)
+			if tt.param == "" {
+				if err != nil {
+					t.Fatalf("ParseSort: %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("ParseSort = %+v, want %+v", got, tt.want)
+				}
+				return
+			}
+
+			appErr, ok := err.(*errors.AppError)
+			if !ok || appErr.Code != http.StatusBadRequest || len(appErr.Fields) != 1 {
+				t.Fatalf("ParseSort error = %v, want a 400 *errors.AppError with one field", err)
+			}
+			if field := appErr.Fields[0]; field.Field != "sort" || field.Code != errors.CodeInvalidValue || field.Param != tt.param {
+				t.Errorf("field error = %s %s %q, want sort %s %q", field.Field, field.Code, field.Param, errors.CodeInvalidValue, tt.param)
+			}
+			if got != nil {
+				t.Errorf("ParseSort = %+v, want nil", got)
+			}
+		})
+	}
+}
+
+type item struct {
+	ID   int    `gorm:"column:id"`
+	Name string `gorm:"column:name"`
+}
+
+func (item) TableName() string {
+	return "item"
+}
+
+// stubConnector is a database/sql driver that answers every page query with pageRows and
+// every COUNT query with count, recording the SQL it was sent
+type stubConnector struct {
+	pageRows [][]driver.Value
+	count    int64
+	queries  []string
+}
+
+func (c *stubConnector) Connect(context.Context) (driver.Conn, error) { return &stubConn{c}, nil }
+func (c *stubConnector) Driver() driver.Driver                        { return nil }
+
+type stubConn struct {
+	connector *stubConnector
+}
+
+func (c *stubConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c *stubConn) Close() error                        { return nil }
+func (c *stubConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+func (c *stubConn) QueryContext(_ context.Context, sql string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.connector.queries = append(c.connector.queries, sql)
+	if strings.HasPrefix(sql, "SELECT count(*)") {
+		return &stubRows{columns: []string{"count"}, values: [][]driver.Value{{c.connector.count}}}, nil
+	}
+
+	columns := []string{"id", "name"}
+	if strings.Contains(sql, "COUNT(*) OVER()") {
+		columns = append(columns, "query_total")
+	}
+	rows := &stubRows{columns: columns}
+	for _, row := range c.connector.pageRows {
+		rows.values = append(rows.values, row[:len(columns)])
+	}
+	return rows, nil
+}
+
+type stubRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *stubRows) Columns() []string { return r.columns }
+func (r *stubRows) Close() error      { return nil }
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func TestFind(t *testing.T) {
+	page := func(ids ...int) [][]driver.Value {
+		var rows [][]driver.Value
+		for _, id := range ids {
+			rows = append(rows, []driver.Value{int64(id), "item", int64(7)})
+		}
+		return rows
+	}
+
+	tests := []struct {
+		name     string
+		spec     query.Spec
+		pageRows [][]driver.Value
+		count    int64
+		ids      []int
+		total    int64
+		// queries are substrings of the SQL expected for each query, in order
+		queries []string
+	}{
+		{
+			name:     "window count reads the total from the page",
+			spec:     query.Spec{Limit: 2, Offset: 2, WindowCount: true},
+			pageRows: page(3, 4),
+			ids:      []int{3, 4},
+			total:    7,
+			queries:  []string{"SELECT *, COUNT(*) OVER() AS query_total FROM \"item\" LIMIT $1 OFFSET $2"},
+		},
+		{
+			name:    "window count on an empty first page",
+			spec:    query.Spec{Limit: 2, WindowCount: true},
+			ids:     []int{},
+			queries: []string{"COUNT(*) OVER()"},
+		},
+		{
+			name:    "window count past the last page falls back to a count",
+			spec:    query.Spec{Limit: 2, Offset: 8, WindowCount: true},
+			count:   7,
+			ids:     []int{},
+			total:   7,
+			queries: []string{"COUNT(*) OVER()", "SELECT count(*) FROM \"item\""},
+		},
+		{
+			name:     "short first page is the total",
+			spec:     query.Spec{Limit: 5},
+			pageRows: page(1, 2, 3),
+			ids:      []int{1, 2, 3},
+			total:    3,
+			queries:  []string{"SELECT * FROM \"item\" LIMIT $1"},
+		},
+		{
+			name:     "full first page is counted",
+			spec:     query.Spec{Limit: 3},
+			pageRows: page(1, 2, 3),
+			count:    7,
+			ids:      []int{1, 2, 3},
+			total:    7,
+			queries:  []string{"SELECT * FROM \"item\" LIMIT $1", "SELECT count(*)"},
+		},
+		{
+			name:     "later page is counted",
+			spec:     query.Spec{Limit: 3, Offset: 6},
+			pageRows: page(7),
+			count:    7,
+			ids:      []int{7},
+			total:    7,
+			queries:  []string{"LIMIT $1 OFFSET $2", "SELECT count(*)"},
+		},
+		{
+			name:     "no limit is the total",
+			spec:     query.Spec{},
+			pageRows: page(1, 2),
+			ids:      []int{1, 2},
+			total:    2,
+			queries:  []string{"SELECT * FROM \"item\""},
+		},
+		{
+			name:     "filters, sort and tie breaker apply to both queries",
+			spec:     query.Spec{Filters: []query.Filter{query.In("id", []int{1, 2, 3})}, Sort: []query.Sort{{Column: "name", Desc: true}}, TieBreaker: "id", Limit: 1},
+			pageRows: page(1),
+			count:    3,
+			ids:      []int{1},
+			total:    3,
+			queries:  []string{"WHERE id IN ($1,$2,$3) ORDER BY \"name\" DESC,\"id\" LIMIT $4", "SELECT count(*) FROM \"item\" WHERE id IN ($1,$2,$3)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connector := &stubConnector{pageRows: tt.pageRows, count: tt.count}
+			db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(connector)}), &gorm.Config{DisableAutomaticPing: true})
+			if err != nil {
+				t.Fatalf("gorm.Open: %v", err)
+			}
+
+			items, total, err := query.Find[item](db.Model(&item{}), tt.spec)
+			if err != nil {
+				t.Fatalf("Find: %v", err)
+			}
+
+			ids := []int{}
+			for _, row := range items {
+				ids = append(ids, row.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.ids) {
+				t.Errorf("ids = %v, want %v", ids, tt.ids)
+			}
+			if total != tt.total {
+				t.Errorf("total = %d, want %d", total, tt.total)
+			}
+			if len(connector.queries) != len(tt.queries) {
+				t.Fatalf("queries = %q, want %d", connector.queries, len(tt.queries))
+			}
+			for i, want := range tt.queries {
+				if !strings.Contains(connector.queries[i], want) {
+					t.Errorf("queries[%d] = %s, want it to contain %s", i, connector.queries[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package sso
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultExpiryDelta is how long before expiry a cached client-credentials token is renewed
+const DefaultExpiryDelta = 30 * time.Second
+
+// ClientCredentialsConfig configures a service-to-service token source
+type ClientCredentialsConfig struct {
+	ClientID     string
+	ClientSecret string
+	TokenURL     string
+	Scopes       []string
+	// Audience is sent as the audience parameter for providers that scope tokens per API
+	Audience string
+	// ExpiryDelta renews tokens this long before they expire, DefaultExpiryDelta when zero
+	ExpiryDelta time.Duration
+	// HTTPClient is used for token requests, a client with DefaultTimeout when nil
+	HTTPClient *http.Client
+}
+
+// ServiceToken is an access token obtained with the client-credentials grant
+type ServiceToken struct {
+	AccessToken string
+	TokenType   string
+	Scope       string
+	// Expiry is zero when the provider did not send expires_in
+	Expiry time.Time
+}
+
+// valid reports whether the token can still be used for at least delta
+func (t *ServiceToken) valid(delta time.Duration) bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || time.Now().Add(delta).Before(t.Expiry)
+}
+
+// TokenSource fetches and caches client-credentials tokens. It is safe for concurrent
+// use; concurrent callers share a single token request.
+type TokenSource struct {
+	config ClientCredentialsConfig
+
+	mu    sync.Mutex
+	token *ServiceToken
+}
+
+// NewTokenSource creates a client-credentials token source
+func NewTokenSource(config ClientCredentialsConfig) *TokenSource {
+	if config.ExpiryDelta == 0 {
+		config.ExpiryDelta = DefaultExpiryDelta
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	return &TokenSource{config: config}
+}
+
+// ClientCredentials returns a token source for this client's credentials and token endpoint
+func (c *OAuth2Client) ClientCredentials(scopes ...string) *TokenSource {
+	return NewTokenSource(ClientCredentialsConfig{
+		ClientID:     c.clientID,
+		ClientSecret: c.clientSecret,
+		TokenURL:     c.endpoints.Token,
+		Scopes:       scopes,
+		HTTPClient:   c.httpClient,
+	})
+}
+
+// Token returns the cached token, requesting a new one when it is missing or about to expire
+func (ts *TokenSource) Token(ctx context.Context) (*ServiceToken, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token.valid(ts.config.ExpiryDelta) {
+		return ts.token, nil
+	}
+
+	token, err := ts.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ts.token = token
+
+	return token, nil
+}
+
+// Invalidate drops the cached token, e.g. after the target service rejected it
+func (ts *TokenSource) Invalidate() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.token = nil
+}
+
+// invalidate drops the cached token only while it is still rejected, so a 401 for an
+// older token does not throw away the one a concurrent request already renewed
+func (ts *TokenSource) invalidate(rejected *ServiceToken) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token == rejected {
+		ts.token = nil
+	}
+}
+
+func (ts *TokenSource) fetch(ctx context.Context) (*ServiceToken, error) {
+	data := url.Values{}
+	data.Set("grant_type", "client_credentials")
+	data.Set("client_id", ts.config.ClientID)
+	data.Set("client_secret", ts.config.ClientSecret)
+	if len(ts.config.Scopes) > 0 {
+		data.Set("scope", strings.Join(ts.config.Scopes, " "))
+	}
+	if ts.config.Audience != "" {
+		data.Set("audience", ts.config.Audience)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.config.TokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, &Error{Op: "client credentials request", Err: err}
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := ts.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, &Error{Op: "client credentials request", Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newResponseError("client credentials request", resp)
+	}
+
+	var tokenResp TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %v", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return nil, &Error{Op: "client credentials request", Err: fmt.Errorf("response has no access_token")}
+	}
+
+	token := &ServiceToken{
+		AccessToken: tokenResp.AccessToken,
+		TokenType:   tokenResp.TokenType,
+		Scope:       tokenResp.Scope,
+	}
+	if tokenResp.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	}
+
+	return token, nil
+}
+
+// Transport is an http.RoundTripper that authenticates requests with tokens from Source.
+// A 401 response drops the cached token, unless it was already renewed since the request was
+// sent, and the request is retried once with a new one when its body can be replayed.
+type Transport struct {
+	Source *TokenSource
+	// Base performs the requests, http.DefaultTransport when nil
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, resp, err := t.roundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+
+	resp.Body.Close()
+	t.Source.invalidate(token)
+
+	_, resp, err = t.roundTrip(retry)
+	return resp, err
+}
+
+// roundTrip sends req with the current token and returns the token it used
+func (t *Transport) roundTrip(req *http.Request) (*ServiceToken, *http.Response, error) {
+	token, err := t.Source.Token(req.Context())
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, nil, err
+	}
+
+	// A RoundTripper must not modify the caller's request
+	authReq := req.Clone(req.Context())
+	authReq.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	resp, err := t.base().RoundTrip(authReq)
+	return token, resp, err
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// Client returns an HTTP client whose requests carry a token from this source
+func (ts *TokenSource) Client(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: &Transport{Source: ts},
+		Timeout:   timeout,
+	}
+}
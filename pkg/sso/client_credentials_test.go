@@ -0,0 +1,174 @@
+package sso_test
+
+import (
+	"context"
+	"djiroutine-go-clean-architecture/pkg/sso"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTokenServer serves client-credentials tokens named token-1, token-2, ... and counts the
+// requests it answered
+func newTokenServer(t *testing.T, expiresIn int, delay time.Duration) (*httptest.Server, *int32) {
+	t.Helper()
+	var issued int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "client_credentials" {
+			http.Error(w, `{"error":"unsupported_grant_type"}`, http.StatusBadRequest)
+			return
+		}
+		time.Sleep(delay)
+		n := atomic.AddInt32(&issued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("token-%d", n),
+			"token_type":   "Bearer",
+			"expires_in":   expiresIn,
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, &issued
+}
+
+func TestTokenSourceCaching(t *testing.T) {
+	tests := []struct {
+		name        string
+		expiresIn   int
+		expiryDelta time.Duration
+		calls       int
+		requests    int32
+		last        string
+	}{
+		{name: "valid token is reused", expiresIn: 3600, calls: 3, requests: 1, last: "token-1"},
+		{name: "token without expiry is reused", expiresIn: 0, calls: 3, requests: 1, last: "token-1"},
+		{name: "token inside the expiry delta is renewed", expiresIn: 60, expiryDelta: time.Minute, calls: 3, requests: 3, last: "token-3"},
+		{name: "token just outside the expiry delta is reused", expiresIn: 60, expiryDelta: 50 * time.Second, calls: 3, requests: 1, last: "token-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, issued := newTokenServer(t, tt.expiresIn, 0)
+			ts := sso.NewTokenSource(sso.ClientCredentialsConfig{
+				ClientID:     "service",
+				ClientSecret: "secret",
+				TokenURL:     server.URL,
+				ExpiryDelta:  tt.expiryDelta,
+			})
+
+			var token *sso.ServiceToken
+			for i := 0; i < tt.calls; i++ {
+				var err error
+				token, err = ts.Token(context.Background())
+				if err != nil {
+					t.Fatalf("Token: %v", err)
+				}
+			}
+			if got := atomic.LoadInt32(issued); got != tt.requests {
+				t.Errorf("token requests = %d, want %d", got, tt.requests)
+			}
+			if token.AccessToken != tt.last {
+				t.Errorf("token = %s, want %s", token.AccessToken, tt.last)
+			}
+		})
+	}
+}
+
+func TestTokenSourceConcurrent(t *testing.T) {
+	server, issued := newTokenServer(t, 3600, 50*time.Millisecond)
+	ts := sso.NewTokenSource(sso.ClientCredentialsConfig{ClientID: "service", ClientSecret: "secret", TokenURL: server.URL})
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 20)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			token, err := ts.Token(context.Background())
+			if err != nil {
+				t.Errorf("Token: %v", err)
+				return
+			}
+			tokens[i] = token.AccessToken
+		}(i)
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(issued); got != 1 {
+		t.Errorf("token requests = %d, want 1", got)
+	}
+	for i, token := range tokens {
+		if token != "token-1" {
+			t.Errorf("tokens[%d] = %s, want token-1", i, token)
+		}
+	}
+}
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTransportUnauthorized(t *testing.T) {
+	tests := []struct {
+		name string
+		// renewed simulates a concurrent request renewing the token while the first one waits
+		renewed  bool
+		requests int32
+		sent     []string
+	}{
+		{name: "rejected token is renewed and the request retried", requests: 2, sent: []string{"Bearer token-1", "Bearer token-2"}},
+		{name: "token renewed meanwhile is kept", renewed: true, requests: 2, sent: []string{"Bearer token-1", "Bearer token-2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, issued := newTokenServer(t, 3600, 0)
+			ts := sso.NewTokenSource(sso.ClientCredentialsConfig{ClientID: "service", ClientSecret: "secret", TokenURL: server.URL})
+
+			var sent []string
+			client := &http.Client{Transport: &sso.Transport{
+				Source: ts,
+				Base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+					sent = append(sent, req.Header.Get("Authorization"))
+					status := http.StatusOK
+					if len(sent) == 1 {
+						status = http.StatusUnauthorized
+						if tt.renewed {
+							ts.Invalidate()
+							if _, err := ts.Token(req.Context()); err != nil {
+								return nil, err
+							}
+						}
+					}
+					rec := httptest.NewRecorder()
+					rec.WriteHeader(status)
+					return rec.Result(), nil
+				}),
+			}}
+
+			resp, err := client.Get("http://service.test/items")
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+			if got := atomic.LoadInt32(issued); got != tt.requests {
+				t.Errorf("token requests = %d, want %d", got, tt.requests)
+			}
+			if fmt.Sprint(sent) != fmt.Sprint(tt.sent) {
+				t.Errorf("sent = %v, want %v", sent, tt.sent)
+			}
+		})
+	}
+}
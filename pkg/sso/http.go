@@ -0,0 +1,189 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxErrorBodySize limits how much of an upstream error body is kept in Error
+const maxErrorBodySize = 4096
+
+// Error is returned when the SSO responds with an unexpected status.
+// It keeps the upstream status and body so callers can log or map them.
+type Error struct {
+	Op         string
+	StatusCode int
+	Body       string
+	Err        error
+}
+
+func (e *Error) Error() string {
+	msg := e.Op
+	if e.StatusCode != 0 {
+		msg = fmt.Sprintf("%s: status %d", msg, e.StatusCode)
+	}
+	if e.Body != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Body)
+	}
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.Err)
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrUnavailable marks SSO responses that could not be used, such as an undecodable body
+var ErrUnavailable = errors.New("sso is unavailable")
+
+// IsUnavailable reports whether err comes from the SSO being unreachable or failing (network
+// errors, 429, 5xx and unusable responses) rather than from the SSO rejecting the token
+func IsUnavailable(err error) bool {
+	if errors.Is(err, ErrUnavailable) {
+		return true
+	}
+
+	var ssoErr *Error
+	if !errors.As(err, &ssoErr) {
+		return false
+	}
+	return ssoErr.StatusCode == 0 || ssoErr.StatusCode == http.StatusTooManyRequests || ssoErr.StatusCode >= http.StatusInternalServerError
+}
+
+// ClientOption configures optional behaviour of OAuth2Client
+type ClientOption func(*OAuth2Client)
+
+// WithHTTPClient replaces the HTTP client used for every call to the SSO
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *OAuth2Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTransport sets the RoundTripper of the HTTP client, e.g. to stub the SSO in tests
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(c *OAuth2Client) {
+		c.transport = transport
+	}
+}
+
+// WithTimeout sets the per-request timeout of the HTTP client
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *OAuth2Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetry retries idempotent requests on network errors, 429 and 5xx responses.
+// The wait between attempts doubles, starting from backoff.
+func WithRetry(maxRetries int, backoff time.Duration) ClientOption {
+	return func(c *OAuth2Client) {
+		c.maxRetries = maxRetries
+		c.retryBackoff = backoff
+	}
+}
+
+// DefaultTimeout is the per-request timeout used when WithTimeout is not given
+const DefaultTimeout = 10 * time.Second
+
+// buildHTTPClient resolves the HTTP client from the options after they are applied. The client
+// given to WithHTTPClient is copied, so setting the transport or timeout does not change it.
+func (c *OAuth2Client) buildHTTPClient() {
+	httpClient := &http.Client{}
+	if c.httpClient != nil {
+		*httpClient = *c.httpClient
+	}
+	c.httpClient = httpClient
+
+	if c.transport != nil {
+		c.httpClient.Transport = c.transport
+	}
+	if c.timeout == 0 {
+		c.timeout = DefaultTimeout
+	}
+	if c.httpClient.Timeout == 0 {
+		c.httpClient.Timeout = c.timeout
+	}
+}
+
+// HTTPClient returns the HTTP client used for calls to the SSO
+func (c *OAuth2Client) HTTPClient() *http.Client {
+	return c.httpClient
+}
+
+// postForm sends a form encoded POST; it is not retried because token requests are not idempotent
+func (c *OAuth2Client) postForm(ctx context.Context, endpoint string, data url.Values, accessToken string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	return c.httpClient.Do(req)
+}
+
+// get sends a GET request, retrying according to WithRetry
+func (c *OAuth2Client) get(ctx context.Context, endpoint, accessToken string) (*http.Response, error) {
+	return doWithRetry(ctx, c.httpClient, c.maxRetries, c.retryBackoff, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accessToken != "" {
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+		}
+		return req, nil
+	})
+}
+
+// doWithRetry executes an idempotent request, retrying transient failures with exponential backoff
+func doWithRetry(ctx context.Context, httpClient *http.Client, maxRetries int, backoff time.Duration, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	wait := backoff
+
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := httpClient.Do(req)
+		retryable := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+		if !retryable || attempt >= maxRetries || ctx.Err() != nil {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// newResponseError reads a bounded part of the body into an Error
+func newResponseError(op string, resp *http.Response) *Error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	return &Error{
+		Op:         op,
+		StatusCode: resp.StatusCode,
+		Body:       strings.TrimSpace(string(body)),
+	}
+}
@@ -0,0 +1,488 @@
+package sso
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrOpaqueToken is returned when the access token is not a JWT and must be checked against userinfo
+	ErrOpaqueToken = errors.New("access token is not a JWT")
+	// ErrInvalidToken is returned when the JWT is malformed or its signature does not verify
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenExpired is returned when the JWT exp claim is in the past
+	ErrTokenExpired = errors.New("token has expired")
+	// ErrTokenNotYetValid is returned when the JWT nbf claim is in the future
+	ErrTokenNotYetValid = errors.New("token is not valid yet")
+	// ErrInvalidIssuer is returned when the JWT iss claim does not match the configured issuer
+	ErrInvalidIssuer = errors.New("token issuer does not match")
+	// ErrInvalidAudience is returned when the JWT aud claim does not contain the configured audience
+	ErrInvalidAudience = errors.New("token audience does not match")
+	// ErrUnknownKey is returned when no JWKS key matches the token kid, even after a refresh
+	ErrUnknownKey = errors.New("signing key not found in JWKS")
+)
+
+// Audience is the JWT aud claim, which may be a single string or an array
+type Audience []string
+
+// UnmarshalJSON accepts both the string and the array form of aud
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+// Contains reports whether the audience includes the given value
+func (a Audience) Contains(value string) bool {
+	for _, v := range a {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Bool is a JSON boolean that also accepts the "true" and "false" strings some providers send
+type Bool bool
+
+// UnmarshalJSON accepts both the boolean and the string form
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	var value bool
+	if err := json.Unmarshal(data, &value); err == nil {
+		*b = Bool(value)
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	*b = Bool(strings.EqualFold(text, "true"))
+	return nil
+}
+
+// Claims holds the registered and profile claims read from a signed token
+type Claims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  Audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	IssuedAt  int64    `json:"iat"`
+	Scope     string   `json:"scope"`
+	Nonce     string   `json:"nonce"`
+	Email     string   `json:"email"`
+	// EmailVerified is the OIDC email_verified claim; emails without it must not be trusted
+	EmailVerified Bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Profile       string `json:"profile"`
+}
+
+// Expiry returns the exp claim as time, or the zero time when the claim is absent
+func (c *Claims) Expiry() time.Time {
+	if c.ExpiresAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+// UserInfo converts the claims into the same shape returned by the userinfo endpoint
+func (c *Claims) UserInfo() *UserInfo {
+	return &UserInfo{
+		Sub:           c.Subject,
+		Email:         c.Email,
+		EmailVerified: c.EmailVerified,
+		Name:          c.Name,
+		Profile:       c.Profile,
+	}
+}
+
+// JWTVerifierConfig holds the settings used to verify signed access tokens locally
+type JWTVerifierConfig struct {
+	JWKSURL  string
+	Issuer   string // iss is not checked when empty
+	Audience string // aud is not checked when empty
+
+	// Leeway tolerates clock skew when checking exp and nbf
+	Leeway time.Duration
+	// CacheTTL is how long fetched keys are trusted before the JWKS is fetched again
+	CacheTTL time.Duration
+	// MinRefreshInterval rate limits JWKS refreshes triggered by an unknown kid
+	MinRefreshInterval time.Duration
+
+	// HTTPClient fetches the JWKS; pass OAuth2Client.HTTPClient() to share its transport
+	HTTPClient *http.Client
+}
+
+// JWTVerifier verifies JWT access tokens against the provider's JWKS
+type JWTVerifier struct {
+	config     JWTVerifierConfig
+	httpClient *http.Client
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	lastRefresh time.Time
+}
+
+// NewJWTVerifier creates a verifier with sane defaults for unset durations
+func NewJWTVerifier(config JWTVerifierConfig) *JWTVerifier {
+	if config.Leeway == 0 {
+		config.Leeway = 30 * time.Second
+	}
+	if config.CacheTTL == 0 {
+		config.CacheTTL = time.Hour
+	}
+	if config.MinRefreshInterval == 0 {
+		config.MinRefreshInterval = time.Minute
+	}
+
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	return &JWTVerifier{
+		config:     config,
+		httpClient: httpClient,
+		keys:       map[string]crypto.PublicKey{},
+	}
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// IsJWT reports whether the token has the three dot separated segments of a JWS compact serialization
+func IsJWT(token string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+
+	var header jwtHeader
+	return json.Unmarshal(headerJSON, &header) == nil && header.Alg != ""
+}
+
+// UnverifiedIssuer returns the iss claim without checking the signature, only to pick the verifying provider
+func UnverifiedIssuer(token string) string {
+	if !IsJWT(token) {
+		return ""
+	}
+
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	if err := decodeSegment(strings.Split(token, ".")[1], &claims); err != nil {
+		return ""
+	}
+	return claims.Issuer
+}
+
+// Verify checks the token signature and its exp, nbf, iss and aud claims
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	if !IsJWT(token) {
+		return nil, ErrOpaqueToken
+	}
+
+	parts := strings.Split(token, ".")
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	key, err := v.getKey(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if err := v.validateClaims(&claims); err != nil {
+		return nil, err
+	}
+
+	return &claims, nil
+}
+
+func (v *JWTVerifier) validateClaims(claims *Claims) error {
+	now := time.Now()
+
+	// A token without exp would never expire, so it is rejected
+	if claims.ExpiresAt == 0 {
+		return fmt.Errorf("%w: missing exp claim", ErrInvalidToken)
+	}
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(v.config.Leeway)) {
+		return ErrTokenExpired
+	}
+
+	if claims.NotBefore != 0 && now.Add(v.config.Leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return ErrTokenNotYetValid
+	}
+
+	if v.config.Issuer != "" && claims.Issuer != v.config.Issuer {
+		return ErrInvalidIssuer
+	}
+
+	if v.config.Audience != "" && !claims.Audience.Contains(v.config.Audience) {
+		return ErrInvalidAudience
+	}
+
+	if claims.Subject == "" {
+		return fmt.Errorf("%w: missing sub claim", ErrInvalidToken)
+	}
+
+	return nil
+}
+
+// getKey returns the key for kid, refreshing the JWKS when the cache is stale or the kid is unknown
+func (v *JWTVerifier) getKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.RLock()
+	key, found := v.lookupKey(kid)
+	stale := time.Since(v.fetchedAt) > v.config.CacheTTL
+	canRefresh := time.Since(v.lastRefresh) > v.config.MinRefreshInterval
+	v.mu.RUnlock()
+
+	if found && !stale {
+		return key, nil
+	}
+
+	// Unknown kid usually means the provider rotated its keys
+	if stale || canRefresh {
+		if err := v.refresh(ctx); err != nil {
+			if found {
+				return key, nil
+			}
+			return nil, err
+		}
+
+		v.mu.RLock()
+		key, found = v.lookupKey(kid)
+		v.mu.RUnlock()
+	}
+
+	if !found {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// lookupKey must be called with v.mu held
+func (v *JWTVerifier) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+func (v *JWTVerifier) refresh(ctx context.Context) error {
+	v.mu.Lock()
+	v.lastRefresh = time.Now()
+	v.mu.Unlock()
+
+	resp, err := doWithRetry(ctx, v.httpClient, 2, 200*time.Millisecond, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, v.config.JWKSURL, nil)
+	})
+	if err != nil {
+		return &Error{Op: "JWKS request", Err: err}
+	}
+	defer resp.Body.Close()
+
+	// Any JWKS failure is on the provider side, whatever the status
+	if resp.StatusCode != http.StatusOK {
+		respErr := newResponseError("JWKS request", resp)
+		respErr.Err = ErrUnavailable
+		return respErr
+	}
+
+	var set jsonWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return &Error{Op: "JWKS request", Err: fmt.Errorf("%w: failed to decode JWKS: %v", ErrUnavailable, err)}
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.fetchedAt = time.Now()
+	v.mu.Unlock()
+
+	return nil
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+	}
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signingInput string, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("%w: unsupported alg %q", ErrInvalidToken, alg)
+	}
+
+	hasher := hash.New()
+	hasher.Write([]byte(signingInput))
+	digest := hasher.Sum(nil)
+
+	switch alg[:2] {
+	case "RS", "PS":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: key type does not match alg %s", ErrInvalidToken, alg)
+		}
+		var err error
+		if alg[:2] == "RS" {
+			err = rsa.VerifyPKCS1v15(rsaKey, hash, digest, signature)
+		} else {
+			err = rsa.VerifyPSS(rsaKey, hash, digest, signature, nil)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+		}
+	case "ES":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: key type does not match alg %s", ErrInvalidToken, alg)
+		}
+		size := (ecKey.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return fmt.Errorf("%w: malformed ECDSA signature", ErrInvalidToken)
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(ecKey, digest, r, s) {
+			return fmt.Errorf("%w: signature verification failed", ErrInvalidToken)
+		}
+	}
+
+	return nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,206 @@
+package sso
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrInvalidNonce is returned when the id_token nonce does not match the one sent in the authorization
+// request, or when no nonce was stored for the request
+var ErrInvalidNonce = errors.New("id_token nonce does not match")
+
+// ErrScopeNotAllowed is returned when an authorization request asks for a scope outside WithAllowedScopes
+var ErrScopeNotAllowed = errors.New("scope is not allowed")
+
+// DefaultScopes are requested when neither WithDefaultScopes nor WithScopes is given
+var DefaultScopes = []string{"read", "write", "profile", "email"}
+
+// Endpoints holds the provider URLs used by the client
+type Endpoints struct {
+	Issuer       string `json:"issuer"`
+	Authorize    string `json:"authorization_endpoint"`
+	Token        string `json:"token_endpoint"`
+	UserInfo     string `json:"userinfo_endpoint"`
+	Revoke       string `json:"revocation_endpoint"`
+	JWKS         string `json:"jwks_uri"`
+	Unauthorized string `json:"-"`
+}
+
+// defaultEndpoints builds the endpoints from the base URL and the paths in DefaultConfig
+func defaultEndpoints(baseURL string) Endpoints {
+	return Endpoints{
+		Authorize:    baseURL + DefaultConfig.AuthorizeEndpoint,
+		Token:        baseURL + DefaultConfig.TokenEndpoint,
+		UserInfo:     baseURL + DefaultConfig.UserInfoEndpoint,
+		Revoke:       baseURL + DefaultConfig.RevokeEndpoint,
+		JWKS:         baseURL + DefaultConfig.JWKSEndpoint,
+		Unauthorized: baseURL + DefaultConfig.UnauthorizedURL,
+	}
+}
+
+// WithDiscovery bootstraps the endpoints from {issuerURL}/.well-known/openid-configuration
+func WithDiscovery(issuerURL string) ClientOption {
+	return func(c *OAuth2Client) {
+		c.discoveryURL = issuerURL
+	}
+}
+
+// WithEndpoints overrides the endpoints derived from the environment
+func WithEndpoints(endpoints Endpoints) ClientOption {
+	return func(c *OAuth2Client) {
+		c.endpoints = endpoints
+	}
+}
+
+// WithDefaultScopes sets the scopes requested when GetAuthorizationURL is called without WithScopes
+func WithDefaultScopes(scopes ...string) ClientOption {
+	return func(c *OAuth2Client) {
+		c.scopes = scopes
+	}
+}
+
+// WithAllowedScopes sets the scopes WithScopes may request. Without it only the default
+// scopes may be requested, so a caller cannot escalate the scopes of a login.
+func WithAllowedScopes(scopes ...string) ClientOption {
+	return func(c *OAuth2Client) {
+		c.allowedScopes = scopes
+	}
+}
+
+// checkScopes returns ErrScopeNotAllowed for the first scope outside the allowed scopes
+func (c *OAuth2Client) checkScopes(scopes []string) error {
+	allowed := c.allowedScopes
+	if len(allowed) == 0 {
+		allowed = c.scopes
+	}
+
+	for _, scope := range scopes {
+		if !hasScope(allowed, scope) {
+			return fmt.Errorf("%w: %s", ErrScopeNotAllowed, scope)
+		}
+	}
+	return nil
+}
+
+// Discover fetches the OpenID Provider metadata and replaces the client endpoints.
+// Endpoints missing from the metadata keep their current value.
+func (c *OAuth2Client) Discover(ctx context.Context, issuerURL string) error {
+	resp, err := c.get(ctx, strings.TrimSuffix(issuerURL, "/")+"/.well-known/openid-configuration", "")
+	if err != nil {
+		return &Error{Op: "discovery request", Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newResponseError("discovery request", resp)
+	}
+
+	var metadata Endpoints
+	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		return &Error{Op: "discovery request", Err: err}
+	}
+
+	if metadata.Issuer == "" || metadata.Authorize == "" || metadata.Token == "" {
+		return &Error{Op: "discovery request", Err: errors.New("metadata is missing issuer, authorization_endpoint or token_endpoint")}
+	}
+
+	// OpenID Connect Discovery requires the issuer to be the URL the metadata was fetched from,
+	// otherwise a compromised or misconfigured document could make us trust another issuer
+	if strings.TrimSuffix(metadata.Issuer, "/") != strings.TrimSuffix(issuerURL, "/") {
+		return &Error{Op: "discovery request", Err: fmt.Errorf("metadata issuer %q does not match %q", metadata.Issuer, issuerURL)}
+	}
+
+	c.endpoints.Issuer = metadata.Issuer
+	c.endpoints.Authorize = metadata.Authorize
+	c.endpoints.Token = metadata.Token
+	if metadata.UserInfo != "" {
+		c.endpoints.UserInfo = metadata.UserInfo
+	}
+	if metadata.Revoke != "" {
+		c.endpoints.Revoke = metadata.Revoke
+	}
+	if metadata.JWKS != "" {
+		c.endpoints.JWKS = metadata.JWKS
+	}
+
+	c.idTokenMu.Lock()
+	c.idTokenVerifier = c.newIDTokenVerifier()
+	c.idTokenMu.Unlock()
+
+	return nil
+}
+
+// getIDTokenVerifier returns the id_token verifier, building it on first use when discovery
+// did not. The client is shared by concurrent callbacks, so the lazy build is locked.
+func (c *OAuth2Client) getIDTokenVerifier() *JWTVerifier {
+	c.idTokenMu.Lock()
+	defer c.idTokenMu.Unlock()
+
+	if c.idTokenVerifier == nil {
+		c.idTokenVerifier = c.newIDTokenVerifier()
+	}
+	return c.idTokenVerifier
+}
+
+// newIDTokenVerifier verifies id_tokens: they must be issued by the provider for this client
+func (c *OAuth2Client) newIDTokenVerifier() *JWTVerifier {
+	return NewJWTVerifier(JWTVerifierConfig{
+		JWKSURL:    c.endpoints.JWKS,
+		Issuer:     c.endpoints.Issuer,
+		Audience:   c.clientID,
+		HTTPClient: c.httpClient,
+	})
+}
+
+// Endpoints returns the provider URLs in use
+func (c *OAuth2Client) Endpoints() Endpoints {
+	return c.endpoints
+}
+
+// Issuer returns the issuer identifier, empty unless set by discovery or WithEndpoints
+func (c *OAuth2Client) Issuer() string {
+	return c.endpoints.Issuer
+}
+
+// AuthURLOption configures a single authorization request
+type AuthURLOption func(*authURLOptions)
+
+type authURLOptions struct {
+	scopes []string
+}
+
+// WithScopes overrides the scopes of a single authorization request
+func WithScopes(scopes ...string) AuthURLOption {
+	return func(o *authURLOptions) {
+		o.scopes = scopes
+	}
+}
+
+// hasScope reports whether scope is in scopes
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyIDToken checks the id_token signature, iss, aud, exp and that its nonce matches the stored one,
+// which is required since an id_token is only expected when openid was requested with a nonce
+func (c *OAuth2Client) verifyIDToken(ctx context.Context, idToken, nonce string) (*Claims, error) {
+	claims, err := c.getIDTokenVerifier().Verify(ctx, idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if nonce == "" || claims.Nonce != nonce {
+		return nil, ErrInvalidNonce
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,276 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultProviderName is used when the single legacy OAUTH_* configuration is loaded
+const DefaultProviderName = "sso"
+
+var (
+	// ErrNoProviders is returned when a token is resolved against an empty registry
+	ErrNoProviders = errors.New("no provider is registered")
+	// ErrUnknownProvider is returned when the named provider is not registered
+	ErrUnknownProvider = errors.New("unknown provider")
+	// ErrUnknownIssuer is returned when the iss claim of a JWT matches no registered provider
+	ErrUnknownIssuer = errors.New("token issuer matches no provider")
+	// ErrProviderRequired is returned for opaque tokens when several providers are registered and none is named
+	ErrProviderRequired = errors.New("provider is required for opaque tokens when several providers are registered")
+)
+
+// Provider is an OAuth2/OIDC identity provider the auth module can log users in with
+type Provider interface {
+	Name() string
+	Issuer() string
+	GetAuthorizationURL(ctx context.Context, state string, opts ...AuthURLOption) (*AuthorizationURL, error)
+	GetAccessToken(ctx context.Context, code, state string) (*TokenResponse, error)
+	RefreshAccessToken(ctx context.Context, refreshToken string) (*TokenResponse, error)
+	VerifyAccessToken(ctx context.Context, accessToken string) (*UserInfo, *Claims, error)
+	GetUserInfo(ctx context.Context, accessToken string) (*UserInfo, error)
+	Logout(ctx context.Context, accessToken, sessionKey string) error
+}
+
+// WithName sets the provider name used in routes and identity links
+func WithName(name string) ClientOption {
+	return func(c *OAuth2Client) {
+		c.name = name
+	}
+}
+
+// Name returns the provider name
+func (c *OAuth2Client) Name() string {
+	return c.name
+}
+
+// Registry holds the configured providers keyed by name; the first registered one is the default
+type Registry struct {
+	mu          sync.RWMutex
+	providers   map[string]Provider
+	names       []string
+	defaultName string
+}
+
+// NewRegistry creates an empty provider registry
+func NewRegistry() *Registry {
+	return &Registry{
+		providers: map[string]Provider{},
+	}
+}
+
+// Register adds a provider; names must be unique
+func (r *Registry) Register(provider Provider) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := provider.Name()
+	if name == "" {
+		return fmt.Errorf("provider name is required")
+	}
+	if _, exists := r.providers[name]; exists {
+		return fmt.Errorf("provider %q is already registered", name)
+	}
+
+	r.providers[name] = provider
+	r.names = append(r.names, name)
+	if r.defaultName == "" {
+		r.defaultName = name
+	}
+
+	return nil
+}
+
+// Get returns the provider by name; an empty name returns the default provider
+func (r *Registry) Get(name string) (Provider, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if name == "" {
+		name = r.defaultName
+	}
+	provider, ok := r.providers[name]
+	return provider, ok
+}
+
+// Default returns the first registered provider
+func (r *Registry) Default() Provider {
+	provider, _ := r.Get("")
+	return provider
+}
+
+// Names returns the provider names in registration order
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]string{}, r.names...)
+}
+
+// ByIssuer returns the provider whose issuer matches iss
+func (r *Registry) ByIssuer(iss string) (Provider, bool) {
+	if iss == "" {
+		return nil, false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, name := range r.names {
+		if r.providers[name].Issuer() == iss {
+			return r.providers[name], true
+		}
+	}
+	return nil, false
+}
+
+// ProviderForToken returns the only provider an access token may be sent to. A JWT is routed by
+// its (unverified) iss claim. Opaque tokens carry nothing to route by, so with several providers
+// the caller must name the provider, e.g. the one the session was created with. name, when set,
+// must also match the issuer of a JWT. Tokens are never tried against other providers, which
+// would hand them to identity providers they were not issued by.
+func (r *Registry) ProviderForToken(accessToken, name string) (Provider, error) {
+	r.mu.RLock()
+	count := len(r.names)
+	r.mu.RUnlock()
+	if count == 0 {
+		return nil, ErrNoProviders
+	}
+
+	iss := UnverifiedIssuer(accessToken)
+
+	if name != "" {
+		provider, ok := r.Get(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+		}
+		if iss != "" && provider.Issuer() != "" && iss != provider.Issuer() {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownIssuer, iss)
+		}
+		return provider, nil
+	}
+
+	if iss != "" {
+		if provider, ok := r.ByIssuer(iss); ok {
+			return provider, nil
+		}
+		// A provider without a known issuer can only be picked when it is the only one
+		if provider := r.Default(); count == 1 && provider.Issuer() == "" {
+			return provider, nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrUnknownIssuer, iss)
+	}
+
+	if count == 1 {
+		return r.Default(), nil
+	}
+	return nil, ErrProviderRequired
+}
+
+// ProviderConfig is the environment configuration of one provider
+type ProviderConfig struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	Environment  Environment
+	DiscoveryURL string
+	Scopes       []string
+	// AllowedScopes are the scopes a login may request with ?scope=; empty allows only Scopes
+	AllowedScopes []string
+
+	LocalVerify bool
+	JWKSURL     string
+	Issuer      string
+	Audience    string
+}
+
+// LoadProviderConfigs reads OAUTH_PROVIDERS (comma separated names) and the
+// OAUTH_<NAME>_* variables of each provider. Without OAUTH_PROVIDERS a single
+// provider named DefaultProviderName is read from the unprefixed OAUTH_* variables.
+func LoadProviderConfigs(getenv func(string) string) []ProviderConfig {
+	names := strings.Split(getenv("OAUTH_PROVIDERS"), ",")
+
+	var configs []ProviderConfig
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "OAUTH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		configs = append(configs, loadProviderConfig(getenv, name, prefix))
+	}
+
+	if len(configs) == 0 {
+		configs = append(configs, loadProviderConfig(getenv, DefaultProviderName, "OAUTH_"))
+	}
+
+	return configs
+}
+
+func loadProviderConfig(getenv func(string) string, name, prefix string) ProviderConfig {
+	environment := Environment(getenv(prefix + "ENVIRONMENT"))
+	if environment == "" {
+		environment = Sandbox // Default to sandbox
+	}
+
+	localVerify, _ := strconv.ParseBool(getenv(prefix + "LOCAL_VERIFY"))
+
+	return ProviderConfig{
+		Name:          name,
+		ClientID:      getenv(prefix + "CLIENT_ID"),
+		ClientSecret:  getenv(prefix + "CLIENT_SECRET"),
+		RedirectURI:   getenv(prefix + "REDIRECT_URI"),
+		Environment:   environment,
+		DiscoveryURL:  getenv(prefix + "DISCOVERY_URL"),
+		Scopes:        strings.Fields(getenv(prefix + "SCOPES")),
+		AllowedScopes: strings.Fields(getenv(prefix + "ALLOWED_SCOPES")),
+		LocalVerify:   localVerify,
+		JWKSURL:       getenv(prefix + "JWKS_URL"),
+		Issuer:        getenv(prefix + "ISSUER"),
+		Audience:      getenv(prefix + "AUDIENCE"),
+	}
+}
+
+// NewOAuth2ClientFromConfig creates a client from a ProviderConfig, enabling discovery and local verification as configured
+func NewOAuth2ClientFromConfig(config ProviderConfig, redisURL string, opts ...ClientOption) (*OAuth2Client, error) {
+	opts = append([]ClientOption{WithName(config.Name)}, opts...)
+	if config.DiscoveryURL != "" {
+		opts = append(opts, WithDiscovery(config.DiscoveryURL))
+	}
+	if len(config.Scopes) > 0 {
+		opts = append(opts, WithDefaultScopes(config.Scopes...))
+	}
+	if len(config.AllowedScopes) > 0 {
+		opts = append(opts, WithAllowedScopes(config.AllowedScopes...))
+	}
+
+	client, err := NewOAuth2Client(config.ClientID, config.ClientSecret, config.RedirectURI, config.Environment, redisURL, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Issuer != "" {
+		client.endpoints.Issuer = config.Issuer
+	}
+
+	// Verify signed access tokens locally instead of calling userinfo on every request
+	if config.LocalVerify {
+		jwksURL := config.JWKSURL
+		if jwksURL == "" {
+			jwksURL = client.JWKSURL()
+		}
+
+		client.SetJWTVerifier(NewJWTVerifier(JWTVerifierConfig{
+			JWKSURL:    jwksURL,
+			Issuer:     client.Issuer(),
+			Audience:   config.Audience,
+			HTTPClient: client.HTTPClient(),
+		}))
+	}
+
+	return client, nil
+}
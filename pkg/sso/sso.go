@@ -6,12 +6,16 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
+	"djiroutine-go-clean-architecture/pkg/store"
+
 	"github.com/go-redis/redis/v8"
 )
 
@@ -33,6 +37,7 @@ type Config struct {
 	UserInfoEndpoint  string
 	RevokeEndpoint    string
 	UnauthorizedURL   string
+	JWKSEndpoint      string
 }
 
 // DefaultConfig provides default configuration values
@@ -45,50 +50,142 @@ var DefaultConfig = Config{
 	UserInfoEndpoint:  "/o/userinfo/",
 	RevokeEndpoint:    "/o/revoke-token/",
 	UnauthorizedURL:   "/o/unauthorized/",
+	JWKSEndpoint:      "/o/.well-known/jwks.json",
 }
 
 // OAuth2Client represents the OAuth2 client
 type OAuth2Client struct {
+	name         string
 	clientID     string
 	clientSecret string
 	redirectURI  string
 	environment  Environment
 	baseURL      string
-	redisClient  *redis.Client
+	store        store.Store
+	jwtVerifier  *JWTVerifier
+
+	endpoints       Endpoints
+	discoveryURL    string
+	scopes          []string
+	allowedScopes   []string
+	idTokenVerifier *JWTVerifier
+	// idTokenMu guards idTokenVerifier, which is built on first use without discovery
+	idTokenMu sync.Mutex
+
+	httpClient   *http.Client
+	transport    http.RoundTripper
+	timeout      time.Duration
+	maxRetries   int
+	retryBackoff time.Duration
+}
+
+// WithStore sets where PKCE verifiers and nonces are kept between the authorization
+// request and the callback. Without it the client connects to Redis at the redisURL given
+// to NewOAuth2Client, and fails with ErrNoStore when that is empty.
+func WithStore(s store.Store) ClientOption {
+	return func(c *OAuth2Client) {
+		c.store = s
+	}
 }
 
-// NewOAuth2Client creates a new OAuth2 client instance
-func NewOAuth2Client(clientID, clientSecret, redirectURI string, environment Environment, redisURL string) (*OAuth2Client, error) {
-	if redisURL == "" {
-		redisURL = DefaultConfig.DefaultRedisURL
+// ErrNoStore is returned by NewOAuth2Client when neither WithStore nor a Redis URL is given
+var ErrNoStore = errors.New("no verifier store: pass WithStore or a Redis URL")
+
+// NewOAuth2Client creates a new OAuth2 client instance. PKCE verifiers and nonces are kept
+// in the store set by WithStore, or else in Redis at redisURL.
+func NewOAuth2Client(clientID, clientSecret, redirectURI string, environment Environment, redisURL string, opts ...ClientOption) (*OAuth2Client, error) {
+	ctx := context.Background()
+
+	baseURL := DefaultConfig.SandboxBaseURL
+	if environment == Production {
+		baseURL = DefaultConfig.ProductionBaseURL
+	}
+
+	client := &OAuth2Client{
+		name:         DefaultProviderName,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURI:  redirectURI,
+		environment:  environment,
+		baseURL:      baseURL,
+		retryBackoff: 200 * time.Millisecond,
+		endpoints:    defaultEndpoints(baseURL),
+		scopes:       DefaultScopes,
+	}
+
+	for _, opt := range opts {
+		opt(client)
+	}
+	client.buildHTTPClient()
+
+	if client.store == nil {
+		if redisURL == "" {
+			return nil, ErrNoStore
+		}
+		redisStore, err := newRedisStore(ctx, redisURL)
+		if err != nil {
+			return nil, err
+		}
+		client.store = redisStore
+	}
+
+	if client.discoveryURL != "" {
+		if err := client.Discover(ctx, client.discoveryURL); err != nil {
+			return nil, fmt.Errorf("failed to discover OpenID configuration: %v", err)
+		}
 	}
 
+	return client, nil
+}
+
+// newRedisStore connects to Redis for the verifier storage
+func newRedisStore(ctx context.Context, redisURL string) (store.Store, error) {
 	opt, err := redis.ParseURL(redisURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse Redis URL: %v", err)
 	}
 
 	redisClient := redis.NewClient(opt)
-	ctx := context.Background()
 
 	// Test Redis connection
 	if err := redisClient.Ping(ctx).Err(); err != nil {
 		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
 	}
 
-	baseURL := DefaultConfig.SandboxBaseURL
-	if environment == Production {
-		baseURL = DefaultConfig.ProductionBaseURL
+	return store.NewRedisStore(redisClient), nil
+}
+
+// SetJWTVerifier enables local verification of signed access tokens
+func (c *OAuth2Client) SetJWTVerifier(verifier *JWTVerifier) {
+	c.jwtVerifier = verifier
+}
+
+// JWKSURL returns the JWKS URL of the configured environment or discovered provider
+func (c *OAuth2Client) JWKSURL() string {
+	return c.endpoints.JWKS
+}
+
+// VerifyAccessToken resolves the user behind an access token.
+// Signed tokens are verified locally when a JWT verifier is set; opaque tokens
+// fall back to a single userinfo call. Claims are nil for opaque tokens.
+func (c *OAuth2Client) VerifyAccessToken(ctx context.Context, accessToken string) (*UserInfo, *Claims, error) {
+	if c.jwtVerifier != nil && IsJWT(accessToken) {
+		claims, err := c.jwtVerifier.Verify(ctx, accessToken)
+		if err != nil {
+			return nil, nil, err
+		}
+		return claims.UserInfo(), claims, nil
 	}
 
-	return &OAuth2Client{
-		clientID:     clientID,
-		clientSecret: clientSecret,
-		redirectURI:  redirectURI,
-		environment:  environment,
-		baseURL:      baseURL,
-		redisClient:  redisClient,
-	}, nil
+	userInfo, err := c.GetUserInfo(ctx, accessToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	if userInfo.Sub == "" {
+		return nil, nil, ErrInvalidToken
+	}
+
+	return userInfo, nil, nil
 }
 
 // GenerateCodeVerifier generates a code verifier for PKCE
@@ -110,8 +207,18 @@ type AuthorizationURL struct {
 	URL string `json:"url"`
 }
 
-// GetAuthorizationURL returns the authorization URL for initiating the OAuth2 flow
-func (c *OAuth2Client) GetAuthorizationURL(state string) (*AuthorizationURL, error) {
+// GetAuthorizationURL returns the authorization URL for initiating the OAuth2 flow.
+// A nonce is generated and bound to the state whenever the openid scope is requested.
+func (c *OAuth2Client) GetAuthorizationURL(ctx context.Context, state string, opts ...AuthURLOption) (*AuthorizationURL, error) {
+	options := authURLOptions{scopes: c.scopes}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if err := c.checkScopes(options.scopes); err != nil {
+		return nil, err
+	}
+
 	verifier, err := c.GenerateCodeVerifier()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate code verifier: %v", err)
@@ -119,10 +226,9 @@ func (c *OAuth2Client) GetAuthorizationURL(state string) (*AuthorizationURL, err
 
 	challenge := c.GenerateCodeChallenge(verifier)
 
-	ctx := context.Background()
 	key := fmt.Sprintf("oauth2_verifier_%s", state)
 
-	err = c.redisClient.Set(ctx, key, verifier, 10*time.Minute).Err()
+	err = c.store.Set(ctx, key, verifier, 10*time.Minute)
 	if err != nil {
 		return nil, fmt.Errorf("failed to store code verifier: %v", err)
 	}
@@ -132,11 +238,24 @@ func (c *OAuth2Client) GetAuthorizationURL(state string) (*AuthorizationURL, err
 	params.Set("client_id", c.clientID)
 	params.Set("redirect_uri", c.redirectURI)
 	params.Set("state", state)
-	params.Set("scope", "read write profile email")
+	params.Set("scope", strings.Join(options.scopes, " "))
 	params.Set("code_challenge", challenge)
 	params.Set("code_challenge_method", "S256")
 
-	authURL := fmt.Sprintf("%s%s?%s", c.baseURL, DefaultConfig.AuthorizeEndpoint, params.Encode())
+	if hasScope(options.scopes, "openid") {
+		nonce, err := c.GenerateCodeVerifier()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate nonce: %v", err)
+		}
+
+		err = c.store.Set(ctx, fmt.Sprintf("oauth2_nonce_%s", state), nonce, 10*time.Minute)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store nonce: %v", err)
+		}
+		params.Set("nonce", nonce)
+	}
+
+	authURL := fmt.Sprintf("%s?%s", c.endpoints.Authorize, params.Encode())
 
 	return &AuthorizationURL{
 		URL: authURL,
@@ -150,18 +269,28 @@ type TokenResponse struct {
 	RefreshToken string `json:"refresh_token"`
 	ExpiresIn    int    `json:"expires_in"`
 	Scope        string `json:"scope"`
+	IDToken      string `json:"id_token,omitempty"`
+
+	// IDTokenClaims is set when the response carried an id_token that passed validation
+	IDTokenClaims *Claims `json:"-"`
 }
 
 // GetAccessToken exchanges authorization code for access token
-func (c *OAuth2Client) GetAccessToken(code, state string) (*TokenResponse, error) {
-	ctx := context.Background()
+func (c *OAuth2Client) GetAccessToken(ctx context.Context, code, state string) (*TokenResponse, error) {
 	key := fmt.Sprintf("oauth2_verifier_%s", state)
 
-	verifier, err := c.redisClient.Get(ctx, key).Result()
+	// The verifier is single use: taking it prevents a second exchange for the same state
+	verifier, err := c.store.Take(ctx, key)
 	if err != nil {
 		return nil, fmt.Errorf("code verifier not found or expired: %v", err)
 	}
 
+	// A nonce is stored only when openid was requested; a store failure must not skip its check
+	nonce, err := c.store.Take(ctx, fmt.Sprintf("oauth2_nonce_%s", state))
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("failed to load nonce: %v", err)
+	}
+
 	data := url.Values{}
 	data.Set("grant_type", "authorization_code")
 	data.Set("code", code)
@@ -170,14 +299,14 @@ func (c *OAuth2Client) GetAccessToken(code, state string) (*TokenResponse, error
 	data.Set("client_secret", c.clientSecret)
 	data.Set("code_verifier", verifier)
 
-	resp, err := http.PostForm(c.baseURL+DefaultConfig.TokenEndpoint, data)
+	resp, err := c.postForm(ctx, c.endpoints.Token, data, "")
 	if err != nil {
-		return nil, fmt.Errorf("failed to get access token: %v", err)
+		return nil, &Error{Op: "token request", Err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("token request failed with status: %d", resp.StatusCode)
+		return nil, newResponseError("token request", resp)
 	}
 
 	var tokenResp TokenResponse
@@ -185,53 +314,94 @@ func (c *OAuth2Client) GetAccessToken(code, state string) (*TokenResponse, error
 		return nil, fmt.Errorf("failed to decode token response: %v", err)
 	}
 
-	// Clean up Redis
-	c.redisClient.Del(ctx, key)
+	if tokenResp.IDToken != "" {
+		claims, err := c.verifyIDToken(ctx, tokenResp.IDToken, nonce)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id_token: %w", err)
+		}
+		tokenResp.IDTokenClaims = claims
+	} else if nonce != "" {
+		return nil, errors.New("token response has no id_token although openid was requested")
+	}
 
 	return &tokenResp, nil
 }
 
-// UserInfo represents the user information response
-type UserInfo struct {
-	Sub     string `json:"sub"`
-	Email   string `json:"email"`
-	Name    string `json:"name"`
-	Profile string `json:"profile"`
+// ErrInvalidGrant is returned when the provider rejects a refresh token as expired, revoked or already used
+var ErrInvalidGrant = errors.New("invalid_grant")
+
+// tokenErrorResponse represents the RFC 6749 error body of the token endpoint
+type tokenErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
 }
 
-// GetUserInfo retrieves user information using the access token
-func (c *OAuth2Client) GetUserInfo(accessToken string) (*UserInfo, error) {
-	req, err := http.NewRequest("GET", c.baseURL+DefaultConfig.UserInfoEndpoint, nil)
+// RefreshAccessToken exchanges a refresh token for a new token set.
+// Providers that rotate refresh tokens return a new one; callers must keep the
+// old one when RefreshToken is empty in the response.
+func (c *OAuth2Client) RefreshAccessToken(ctx context.Context, refreshToken string) (*TokenResponse, error) {
+	data := url.Values{}
+	data.Set("grant_type", "refresh_token")
+	data.Set("refresh_token", refreshToken)
+	data.Set("client_id", c.clientID)
+	data.Set("client_secret", c.clientSecret)
+
+	resp, err := c.postForm(ctx, c.endpoints.Token, data, "")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
+		return nil, &Error{Op: "refresh token request", Err: err}
 	}
+	defer resp.Body.Close()
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if resp.StatusCode != http.StatusOK {
+		respErr := newResponseError("refresh token request", resp)
 
-	client := &http.Client{}
-	fmt.Println(req)
-	resp, err := client.Do(req)
+		var errResp tokenErrorResponse
+		if json.Unmarshal([]byte(respErr.Body), &errResp) == nil && errResp.Error == "invalid_grant" {
+			respErr.Err = ErrInvalidGrant
+		}
+		return nil, respErr
+	}
 
+	var tokenResp TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %v", err)
+	}
+
+	return &tokenResp, nil
+}
+
+// UserInfo represents the user information response
+type UserInfo struct {
+	Sub           string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified Bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Profile       string `json:"profile"`
+}
+
+// GetUserInfo retrieves user information using the access token
+func (c *OAuth2Client) GetUserInfo(ctx context.Context, accessToken string) (*UserInfo, error) {
+	resp, err := c.get(ctx, c.endpoints.UserInfo, accessToken)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user info: %v", err)
+		return nil, &Error{Op: "user info request", Err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("user info request failed with status: %d", resp.StatusCode)
+		return nil, newResponseError("user info request", resp)
 	}
 
 	var userInfo UserInfo
 	if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
-		return nil, fmt.Errorf("failed to decode user info: %v", err)
+		return nil, &Error{Op: "user info request", Err: fmt.Errorf("%w: failed to decode user info: %v", ErrUnavailable, err)}
 	}
 
 	return &userInfo, nil
 }
 
 // IsAuthenticated checks if the user is authenticated using the access token
-func (c *OAuth2Client) IsAuthenticated(accessToken string) bool {
-	userInfo, err := c.GetUserInfo(accessToken)
+func (c *OAuth2Client) IsAuthenticated(ctx context.Context, accessToken string) bool {
+	userInfo, err := c.GetUserInfo(ctx, accessToken)
 	if err != nil {
 		return false
 	}
@@ -239,27 +409,18 @@ func (c *OAuth2Client) IsAuthenticated(accessToken string) bool {
 }
 
 // Logout revokes the access token and logs out the user
-func (c *OAuth2Client) Logout(accessToken, sessionKey string) error {
+func (c *OAuth2Client) Logout(ctx context.Context, accessToken, sessionKey string) error {
 	data := url.Values{}
 	data.Set("pjnhk_id", sessionKey)
 
-	req, err := http.NewRequest("POST", c.baseURL+DefaultConfig.RevokeEndpoint, strings.NewReader(data.Encode()))
-	if err != nil {
-		return fmt.Errorf("failed to create logout request: %v", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := c.postForm(ctx, c.endpoints.Revoke, data, accessToken)
 	if err != nil {
-		return fmt.Errorf("logout request failed: %v", err)
+		return &Error{Op: "logout request", Err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("logout failed with status: %d", resp.StatusCode)
+		return newResponseError("logout request", resp)
 	}
 
 	return nil
@@ -269,5 +430,5 @@ func (c *OAuth2Client) Logout(accessToken, sessionKey string) error {
 func (c *OAuth2Client) GetUnauthorizedURL() string {
 	params := url.Values{}
 	params.Set("client_id", c.clientID)
-	return fmt.Sprintf("%s%s?%s", c.baseURL, DefaultConfig.UnauthorizedURL, params.Encode())
+	return fmt.Sprintf("%s?%s", c.endpoints.Unauthorized, params.Encode())
 }
@@ -0,0 +1,328 @@
+package ssotest_test
+
+import (
+	"context"
+	"djiroutine-go-clean-architecture/internal/entity"
+	"djiroutine-go-clean-architecture/internal/http/routes"
+	"djiroutine-go-clean-architecture/internal/modules/auth"
+	authRepository "djiroutine-go-clean-architecture/internal/modules/auth/repository"
+	authUsecase "djiroutine-go-clean-architecture/internal/modules/auth/usercase"
+	"djiroutine-go-clean-architecture/pkg/errors"
+	"djiroutine-go-clean-architecture/pkg/logger"
+	"djiroutine-go-clean-architecture/pkg/sso"
+	"djiroutine-go-clean-architecture/pkg/sso/ssotest"
+	"djiroutine-go-clean-architecture/pkg/store"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// TestLoginFlow runs login, callback, an authenticated API call and logout against the mock
+// provider, with the auth module wired as in cmd/api and every store kept in memory
+func TestLoginFlow(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ssotest.Config
+		scopes []string
+	}{
+		{name: "opaque access token", scopes: []string{"read", "profile", "email"}},
+		{name: "JWT access token", config: ssotest.Config{JWTAccessTokens: true}, scopes: []string{"read", "profile", "email"}},
+		{name: "openid id_token", config: ssotest.Config{RequirePKCE: true}, scopes: []string{"openid", "profile", "email"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.ClientID = "app"
+			tt.config.ClientSecret = "secret"
+			provider := ssotest.NewServer(tt.config)
+			defer provider.Close()
+
+			app, users := newApp(t, provider, tt.config.JWTAccessTokens, tt.scopes)
+
+			// Login returns the authorization URL and binds the state to this browser
+			rec := app.do(http.MethodGet, "/auth/login", "", nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("login: status %d: %s", rec.Code, rec.Body)
+			}
+			var login struct {
+				AuthURL string `json:"auth_url"`
+				State   string `json:"state"`
+			}
+			decode(t, rec, &login)
+			stateCookie := findCookie(rec, auth.DefaultStateConfig.CookieName)
+			if stateCookie == nil {
+				t.Fatal("login: state cookie is not set")
+			}
+			if stateCookie.SameSite != http.SameSiteLaxMode {
+				t.Errorf("login: state cookie SameSite = %v, want Lax", stateCookie.SameSite)
+			}
+
+			code, state, err := provider.Authorize(login.AuthURL, "")
+			if err != nil {
+				t.Fatalf("authorize: %v", err)
+			}
+			if state != login.State {
+				t.Fatalf("authorize: state = %q, want %q", state, login.State)
+			}
+
+			// Callback exchanges the code and provisions the local user
+			query := url.Values{"code": {code}, "state": {state}}
+			rec = app.do(http.MethodGet, "/auth/callback?"+query.Encode(), "", stateCookie)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("callback: status %d: %s", rec.Code, rec.Body)
+			}
+			var callback struct {
+				User  auth.User  `json:"user"`
+				Token auth.Token `json:"token"`
+			}
+			decode(t, rec, &callback)
+			if callback.User.ID != ssotest.DefaultUser.Sub || callback.User.LocalID == 0 {
+				t.Fatalf("callback: user = %+v", callback.User)
+			}
+			if callback.Token.AccessToken == "" || callback.Token.RefreshToken == "" {
+				t.Fatalf("callback: token = %+v", callback.Token)
+			}
+
+			// The callback state is single use
+			rec = app.do(http.MethodGet, "/auth/callback?"+query.Encode(), "", stateCookie)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("replayed callback: status %d, want 400", rec.Code)
+			}
+
+			accessToken := callback.Token.AccessToken
+			rec = app.do(http.MethodGet, "/api/users", accessToken, nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("users: status %d: %s", rec.Code, rec.Body)
+			}
+			var list struct {
+				Data []entity.UserResponse `json:"data"`
+			}
+			decode(t, rec, &list)
+			if len(list.Data) != 1 || list.Data[0].Email != ssotest.DefaultUser.Email {
+				t.Fatalf("users: data = %+v", list.Data)
+			}
+
+			// A second call is served from the token cache
+			app.do(http.MethodGet, "/api/users", accessToken, nil)
+			if users.provisioned != 1 {
+				t.Errorf("users: provisioned %d users, want 1", users.provisioned)
+			}
+
+			rec = app.do(http.MethodPost, "/auth/logout", accessToken, nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("logout: status %d: %s", rec.Code, rec.Body)
+			}
+			if n := provider.Requests(ssotest.EndpointRevoke); n != 1 {
+				t.Errorf("logout: revoke requests = %d, want 1", n)
+			}
+
+			rec = app.do(http.MethodGet, "/api/users", accessToken, nil)
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("users after logout: status %d, want 401", rec.Code)
+			}
+		})
+	}
+}
+
+type app struct {
+	echo *echo.Echo
+}
+
+func newApp(t *testing.T, provider *ssotest.Server, localVerify bool, scopes []string) (*app, *fakeUsers) {
+	t.Helper()
+
+	config := sso.ProviderConfig{
+		Name:         sso.DefaultProviderName,
+		ClientID:     "app",
+		ClientSecret: "secret",
+		RedirectURI:  "http://app.test/auth/callback",
+		Environment:  sso.Sandbox,
+		Scopes:       scopes,
+		LocalVerify:  localVerify,
+	}
+
+	client, err := sso.NewOAuth2ClientFromConfig(config, "", provider.ClientOptions()...)
+	if err != nil {
+		t.Fatalf("NewOAuth2ClientFromConfig: %v", err)
+	}
+	providers := sso.NewRegistry()
+	if err := providers.Register(client); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	authConfig := auth.Config{
+		CacheTTL:      time.Minute,
+		Session:       auth.DefaultSessionConfig,
+		State:         auth.DefaultStateConfig,
+		Impersonation: auth.DefaultImpersonationConfig,
+	}
+	authConfig.State.Secret = []byte("test-state-secret")
+
+	users := &fakeUsers{}
+	audit := fakeAudit{}
+	authRepo := authRepository.NewAuthRepository(store.NewMemoryStore(), store.NewMemoryStore(), logger.L)
+	authUC := authUsecase.NewAuthUseCase(providers, authRepo, users, audit, authConfig, logger.L)
+
+	e := echo.New()
+	routes.SetupRoutes(e, map[string]interface{}{
+		"auth":   authUC,
+		"user":   users,
+		"rbac":   fakeRBAC{},
+		"apikey": fakeAPIKeys{},
+		"audit":  audit,
+	})
+
+	return &app{echo: e}, users
+}
+
+func (a *app) do(method, target, accessToken string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+
+	rec := httptest.NewRecorder()
+	a.echo.ServeHTTP(rec, req)
+	return rec
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %s: %v", rec.Body, err)
+	}
+}
+
+func findCookie(rec *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+// fakeUsers keeps provisioned users in memory in place of the Postgres backed user module
+type fakeUsers struct {
+	mu          sync.Mutex
+	users       []*entity.UserResponse
+	identities  map[string]int
+	provisioned int
+}
+
+func (f *fakeUsers) ListUsers(ctx context.Context, request *entity.UserRequestList) ([]*entity.UserResponse, int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.users, int64(len(f.users)), nil
+}
+
+func (f *fakeUsers) GetUser(ctx context.Context, id int) (*entity.UserResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, user := range f.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return nil, errors.ErrNotFound
+}
+
+func (f *fakeUsers) CreateUser(ctx context.Context, request *entity.UserRequest) (*entity.UserResponse, error) {
+	return nil, errors.ErrForbidden
+}
+
+func (f *fakeUsers) UpdateUser(ctx context.Context, id int, request *entity.UserRequest) (*entity.UserResponse, error) {
+	return nil, errors.ErrForbidden
+}
+
+func (f *fakeUsers) PatchUser(ctx context.Context, id int, request *entity.UserPatchRequest) (*entity.UserResponse, error) {
+	return nil, errors.ErrForbidden
+}
+
+func (f *fakeUsers) DeleteUser(ctx context.Context, id int) error {
+	return errors.ErrForbidden
+}
+
+func (f *fakeUsers) ProvisionExternalUser(ctx context.Context, external *entity.ExternalUser) (*entity.UserResponse, error) {
+	return f.ResolveExternalUser(ctx, external)
+}
+
+func (f *fakeUsers) ResolveExternalUser(ctx context.Context, external *entity.ExternalUser) (*entity.UserResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.identities == nil {
+		f.identities = map[string]int{}
+	}
+	key := external.Provider + ":" + external.Subject
+	if id, ok := f.identities[key]; ok {
+		return f.users[id-1], nil
+	}
+
+	user := &entity.UserResponse{ID: len(f.users) + 1, Username: external.Subject, Email: external.Email}
+	f.users = append(f.users, user)
+	f.identities[key] = user.ID
+	f.provisioned++
+	return user, nil
+}
+
+// fakeRBAC lets every user provisioned through the login read users
+type fakeRBAC struct{}
+
+func (fakeRBAC) GetPermissions(ctx context.Context, user *auth.User) ([]string, error) {
+	if user.LocalID == 0 {
+		return nil, nil
+	}
+	return []string{"users:read"}, nil
+}
+
+func (r fakeRBAC) HasPermission(ctx context.Context, user *auth.User, permission string) (bool, error) {
+	permissions, err := r.GetPermissions(ctx, user)
+	for _, p := range permissions {
+		if p == permission {
+			return true, err
+		}
+	}
+	return false, err
+}
+
+type fakeAPIKeys struct{}
+
+func (fakeAPIKeys) ListKeys(ctx context.Context, caller *auth.User) ([]*entity.APIKeyResponse, error) {
+	return nil, nil
+}
+
+func (fakeAPIKeys) CreateKey(ctx context.Context, caller *auth.User, request *entity.APIKeyRequest) (*entity.APIKeySecretResponse, error) {
+	return nil, errors.ErrForbidden
+}
+
+func (fakeAPIKeys) RotateKey(ctx context.Context, caller *auth.User, id int) (*entity.APIKeySecretResponse, error) {
+	return nil, errors.ErrForbidden
+}
+
+func (fakeAPIKeys) RevokeKey(ctx context.Context, caller *auth.User, id int) error {
+	return errors.ErrForbidden
+}
+
+func (fakeAPIKeys) Authenticate(ctx context.Context, key string) (*auth.User, error) {
+	return nil, errors.ErrUnAuthorize
+}
+
+type fakeAudit struct{}
+
+func (fakeAudit) Record(ctx context.Context, event *entity.AuditEvent) {}
+
+func (fakeAudit) ListEvents(ctx context.Context, request *entity.AuditRequestList) ([]*entity.AuditEvent, int64, error) {
+	return nil, 0, nil
+}
+
+func (fakeAudit) Close() {}
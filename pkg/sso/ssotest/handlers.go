@@ -0,0 +1,427 @@
+package ssotest
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// discovery serves the OpenID Provider metadata
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	endpoints := s.Endpoints()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                endpoints.Issuer,
+		"authorization_endpoint":                endpoints.Authorize,
+		"token_endpoint":                        endpoints.Token,
+		"userinfo_endpoint":                     endpoints.UserInfo,
+		"revocation_endpoint":                   endpoints.Revoke,
+		"jwks_uri":                              endpoints.JWKS,
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "refresh_token", "client_credentials"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+// jwks serves the public signing key
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": s.keyID,
+			"n":   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize logs in the user named by login_hint (or the first user) without a consent page
+// and redirects back with a code
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Get("client_id") != s.config.ClientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+
+	redirectURI := query.Get("redirect_uri")
+	if !s.allowedRedirect(redirectURI) {
+		http.Error(w, "redirect_uri is not allowed", http.StatusBadRequest)
+		return
+	}
+
+	state := query.Get("state")
+	if query.Get("response_type") != "code" {
+		redirectWithError(w, r, redirectURI, state, "unsupported_response_type")
+		return
+	}
+
+	challenge := query.Get("code_challenge")
+	if challenge == "" && s.config.RequirePKCE {
+		redirectWithError(w, r, redirectURI, state, "invalid_request")
+		return
+	}
+	if challenge != "" && query.Get("code_challenge_method") != "S256" {
+		redirectWithError(w, r, redirectURI, state, "invalid_request")
+		return
+	}
+
+	s.mu.Lock()
+	sub := query.Get("login_hint")
+	if sub == "" {
+		sub = s.config.Users[0].Sub
+	}
+	_, known := s.users[sub]
+
+	code := randomString(24)
+	if known {
+		s.codes[code] = &authCode{
+			clientID:    s.config.ClientID,
+			redirectURI: redirectURI,
+			challenge:   challenge,
+			scope:       query.Get("scope"),
+			nonce:       query.Get("nonce"),
+			sub:         sub,
+			expiresAt:   time.Now().Add(s.config.CodeTTL),
+		}
+	}
+	s.mu.Unlock()
+
+	if !known {
+		redirectWithError(w, r, redirectURI, state, "access_denied")
+		return
+	}
+
+	params := url.Values{}
+	params.Set("code", code)
+	params.Set("state", state)
+	http.Redirect(w, r, appendQuery(redirectURI, params), http.StatusFound)
+}
+
+// token implements the authorization_code, refresh_token and client_credentials grants
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeTokenError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != s.config.ClientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(s.config.ClientSecret)) != 1 {
+		writeTokenError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		s.exchangeCode(w, r)
+	case "refresh_token":
+		s.refresh(w, r)
+	case "client_credentials":
+		s.clientCredentials(w, r, clientID)
+	default:
+		writeTokenError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+	}
+}
+
+func (s *Server) exchangeCode(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code, ok := s.codes[r.PostForm.Get("code")]
+	if !ok || time.Now().After(code.expiresAt) {
+		writeTokenError(w, http.StatusBadRequest, "invalid_grant", "authorization code is invalid or expired")
+		return
+	}
+
+	// A replayed code revokes everything issued from it
+	if code.used {
+		for _, token := range code.issuedAccess {
+			delete(s.accessTokens, token)
+		}
+		for _, token := range code.issuedRefresh {
+			delete(s.refreshTokens, token)
+		}
+		writeTokenError(w, http.StatusBadRequest, "invalid_grant", "authorization code was already used")
+		return
+	}
+
+	if r.PostForm.Get("redirect_uri") != code.redirectURI {
+		writeTokenError(w, http.StatusBadRequest, "invalid_grant", "redirect_uri does not match the authorization request")
+		return
+	}
+
+	if code.challenge != "" {
+		verifier := r.PostForm.Get("code_verifier")
+		hash := sha256.Sum256([]byte(verifier))
+		if verifier == "" || base64.RawURLEncoding.EncodeToString(hash[:]) != code.challenge {
+			writeTokenError(w, http.StatusBadRequest, "invalid_grant", "code_verifier does not match code_challenge")
+			return
+		}
+	}
+
+	code.used = true
+
+	resp, accessToken, refreshToken := s.issueTokens(code.sub, code.scope, code.nonce, true)
+	code.issuedAccess = append(code.issuedAccess, accessToken)
+	code.issuedRefresh = append(code.issuedRefresh, refreshToken)
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) refresh(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refreshToken := r.PostForm.Get("refresh_token")
+	g, ok := s.refreshTokens[refreshToken]
+	if !ok {
+		writeTokenError(w, http.StatusBadRequest, "invalid_grant", "refresh token is invalid, expired or revoked")
+		return
+	}
+
+	resp, _, newRefreshToken := s.issueTokens(g.sub, g.scope, "", s.config.RotateRefreshTokens)
+	if s.config.RotateRefreshTokens {
+		delete(s.refreshTokens, refreshToken)
+	} else {
+		delete(s.refreshTokens, newRefreshToken)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// clientCredentials issues an access token for the client itself, without refresh or id_token
+func (s *Server) clientCredentials(w http.ResponseWriter, r *http.Request, clientID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	scope := r.PostForm.Get("scope")
+
+	accessToken := randomString(32)
+	if s.config.JWTAccessTokens {
+		accessToken = s.sign(map[string]interface{}{
+			"iss":   s.issuer,
+			"sub":   clientID,
+			"aud":   clientID,
+			"iat":   now.Unix(),
+			"exp":   now.Add(s.config.AccessTokenTTL).Unix(),
+			"jti":   randomString(12),
+			"scope": scope,
+		})
+	}
+	s.accessTokens[accessToken] = &grant{sub: clientID, scope: scope, expiresAt: now.Add(s.config.AccessTokenTTL)}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(s.config.AccessTokenTTL.Seconds()),
+		"scope":        scope,
+	})
+}
+
+// issueTokens creates an access token, a refresh token and, for the openid scope, an id_token.
+// The refresh token is left out of the response when includeRefresh is false. Callers hold s.mu.
+func (s *Server) issueTokens(sub, scope, nonce string, includeRefresh bool) (map[string]interface{}, string, string) {
+	now := time.Now()
+	user := s.users[sub]
+
+	accessToken := randomString(32)
+	if s.config.JWTAccessTokens {
+		accessToken = s.sign(map[string]interface{}{
+			"iss":            s.issuer,
+			"sub":            sub,
+			"aud":            s.config.ClientID,
+			"iat":            now.Unix(),
+			"exp":            now.Add(s.config.AccessTokenTTL).Unix(),
+			"jti":            randomString(12),
+			"scope":          scope,
+			"email":          user.Email,
+			"email_verified": user.EmailVerified,
+			"name":           user.Name,
+		})
+	}
+	refreshToken := randomString(32)
+
+	s.accessTokens[accessToken] = &grant{sub: sub, scope: scope, expiresAt: now.Add(s.config.AccessTokenTTL)}
+	s.refreshTokens[refreshToken] = &grant{sub: sub, scope: scope}
+
+	resp := map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(s.config.AccessTokenTTL.Seconds()),
+		"scope":        scope,
+	}
+	if includeRefresh {
+		resp["refresh_token"] = refreshToken
+	}
+
+	if hasScope(scope, "openid") {
+		claims := map[string]interface{}{
+			"iss":            s.issuer,
+			"sub":            sub,
+			"aud":            s.config.ClientID,
+			"iat":            now.Unix(),
+			"exp":            now.Add(s.config.AccessTokenTTL).Unix(),
+			"email":          user.Email,
+			"email_verified": user.EmailVerified,
+			"name":           user.Name,
+			"profile":        user.Profile,
+		}
+		if nonce != "" {
+			claims["nonce"] = nonce
+		}
+		resp["id_token"] = s.sign(claims)
+	}
+
+	return resp, accessToken, refreshToken
+}
+
+// userInfo returns the user of a valid bearer token
+func (s *Server) userInfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	g, ok := s.accessTokens[bearerToken(r)]
+	valid := ok && time.Now().Before(g.expiresAt)
+	var user User
+	if valid {
+		user = s.users[g.sub]
+	}
+	s.mu.Unlock()
+
+	if !valid {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeTokenError(w, http.StatusUnauthorized, "invalid_token", "access token is invalid or expired")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+// revoke ends the session of the bearer token's user, or revokes a single token passed as
+// the RFC 7009 token parameter. Unknown tokens are accepted as required by RFC 7009.
+func (s *Server) revoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.ParseForm()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if token := r.PostForm.Get("token"); token != "" {
+		delete(s.accessTokens, token)
+		delete(s.refreshTokens, token)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	g, ok := s.accessTokens[bearerToken(r)]
+	if !ok {
+		writeTokenError(w, http.StatusUnauthorized, "invalid_token", "access token is invalid or revoked")
+		return
+	}
+
+	for token, other := range s.accessTokens {
+		if other.sub == g.sub {
+			delete(s.accessTokens, token)
+		}
+	}
+	for token, other := range s.refreshTokens {
+		if other.sub == g.sub {
+			delete(s.refreshTokens, token)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// sign creates an RS256 JWT
+func (s *Server) sign(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": s.keyID})
+	payload, _ := json.Marshal(claims)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(signingInput))
+
+	signature, err := rsa.SignPKCS1v15(nil, s.key, crypto.SHA256, hash[:])
+	if err != nil {
+		panic(err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (s *Server) allowedRedirect(redirectURI string) bool {
+	if redirectURI == "" {
+		return false
+	}
+	if len(s.config.RedirectURIs) == 0 {
+		return true
+	}
+	for _, allowed := range s.config.RedirectURIs {
+		if allowed == redirectURI {
+			return true
+		}
+	}
+	return false
+}
+
+func bearerToken(r *http.Request) string {
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+func hasScope(scope, want string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
+
+func appendQuery(rawURL string, params url.Values) string {
+	if strings.Contains(rawURL, "?") {
+		return rawURL + "&" + params.Encode()
+	}
+	return rawURL + "?" + params.Encode()
+}
+
+func redirectWithError(w http.ResponseWriter, r *http.Request, redirectURI, state, errCode string) {
+	params := url.Values{}
+	params.Set("error", errCode)
+	params.Set("state", state)
+	http.Redirect(w, r, appendQuery(redirectURI, params), http.StatusFound)
+}
+
+func writeTokenError(w http.ResponseWriter, status int, errCode, description string) {
+	writeJSON(w, status, map[string]string{
+		"error":             errCode,
+		"error_description": description,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
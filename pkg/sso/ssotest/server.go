@@ -0,0 +1,351 @@
+// Package ssotest provides an in-process OAuth2/OpenID Connect provider that
+// speaks the same protocol as the SSO, so pkg/sso and the /auth handlers can be
+// exercised offline.
+//
+//	srv := ssotest.NewServer(ssotest.Config{ClientID: "app", ClientSecret: "secret"})
+//	defer srv.Close()
+//
+//	client, _ := sso.NewOAuth2Client("app", "secret", redirectURI, sso.Sandbox, "", srv.ClientOptions()...)
+//	authURL, _ := client.GetAuthorizationURL(ctx, state)
+//	code, state, _ := srv.Authorize(authURL.URL, "alice")
+//	token, _ := client.GetAccessToken(ctx, code, state)
+package ssotest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"djiroutine-go-clean-architecture/pkg/sso"
+	"djiroutine-go-clean-architecture/pkg/store"
+)
+
+// Endpoint names accepted by Fail and Requests
+const (
+	EndpointDiscovery = "discovery"
+	EndpointJWKS      = "jwks"
+	EndpointAuthorize = "authorize"
+	EndpointToken     = "token"
+	EndpointUserInfo  = "userinfo"
+	EndpointRevoke    = "revoke"
+)
+
+// User is an account the mock provider can log in
+type User struct {
+	Sub           string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Profile       string `json:"profile"`
+}
+
+// Config configures the mock provider
+type Config struct {
+	ClientID     string
+	ClientSecret string
+
+	// RedirectURIs restricts the accepted redirect_uri values; empty accepts any
+	RedirectURIs []string
+
+	// Users that can log in; the first one is used when no login hint is given.
+	// A single default user is created when empty.
+	Users []User
+
+	// AccessTokenTTL is the lifetime of access tokens, 1 hour when zero
+	AccessTokenTTL time.Duration
+	// CodeTTL is the lifetime of authorization codes, 1 minute when zero
+	CodeTTL time.Duration
+
+	// JWTAccessTokens issues signed JWT access tokens instead of opaque ones
+	JWTAccessTokens bool
+	// RotateRefreshTokens issues a new refresh token on every refresh and invalidates the old one
+	RotateRefreshTokens bool
+	// RequirePKCE rejects authorization requests without an S256 code_challenge
+	RequirePKCE bool
+}
+
+// DefaultUser is the account available when Config.Users is empty
+var DefaultUser = User{
+	Sub:           "ssotest-user",
+	Email:         "user@example.com",
+	EmailVerified: true,
+	Name:          "Test User",
+}
+
+// Failure is returned by an endpoint instead of its normal response
+type Failure struct {
+	StatusCode int
+	Body       string
+	// Times is how many requests fail; zero fails until ClearFailures is called
+	Times int
+}
+
+type authCode struct {
+	clientID      string
+	redirectURI   string
+	challenge     string
+	scope         string
+	nonce         string
+	sub           string
+	expiresAt     time.Time
+	used          bool
+	issuedAccess  []string
+	issuedRefresh []string
+}
+
+type grant struct {
+	sub       string
+	scope     string
+	expiresAt time.Time
+}
+
+// Server is a mock OAuth2/OIDC provider
+type Server struct {
+	config  Config
+	issuer  string
+	key     *rsa.PrivateKey
+	keyID   string
+	handler *http.ServeMux
+	server  *httptest.Server
+
+	mu            sync.Mutex
+	users         map[string]User
+	codes         map[string]*authCode
+	accessTokens  map[string]*grant
+	refreshTokens map[string]*grant
+	failures      map[string]*Failure
+	requests      map[string]int
+}
+
+// New creates a mock provider that is served by the caller, e.g. with http.ListenAndServe.
+// issuer is the external base URL the provider is reachable at.
+func New(issuer string, config Config) (*Server, error) {
+	if config.ClientID == "" {
+		return nil, errors.New("ssotest: ClientID is required")
+	}
+	if config.AccessTokenTTL == 0 {
+		config.AccessTokenTTL = time.Hour
+	}
+	if config.CodeTTL == 0 {
+		config.CodeTTL = time.Minute
+	}
+	if len(config.Users) == 0 {
+		config.Users = []User{DefaultUser}
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("ssotest: failed to generate signing key: %v", err)
+	}
+
+	s := &Server{
+		config:        config,
+		issuer:        strings.TrimSuffix(issuer, "/"),
+		key:           key,
+		keyID:         randomString(8),
+		users:         map[string]User{},
+		codes:         map[string]*authCode{},
+		accessTokens:  map[string]*grant{},
+		refreshTokens: map[string]*grant{},
+		failures:      map[string]*Failure{},
+		requests:      map[string]int{},
+	}
+	for _, user := range config.Users {
+		s.users[user.Sub] = user
+	}
+
+	s.handler = http.NewServeMux()
+	s.handle("/.well-known/openid-configuration", EndpointDiscovery, s.discovery)
+	s.handle(sso.DefaultConfig.JWKSEndpoint, EndpointJWKS, s.jwks)
+	s.handle(sso.DefaultConfig.AuthorizeEndpoint, EndpointAuthorize, s.authorize)
+	s.handle(sso.DefaultConfig.TokenEndpoint, EndpointToken, s.token)
+	s.handle(sso.DefaultConfig.UserInfoEndpoint, EndpointUserInfo, s.userInfo)
+	s.handle(sso.DefaultConfig.RevokeEndpoint, EndpointRevoke, s.revoke)
+
+	return s, nil
+}
+
+// NewServer starts a mock provider on a local loopback port; call Close when done
+func NewServer(config Config) *Server {
+	ts := httptest.NewUnstartedServer(nil)
+
+	s, err := New("http://"+ts.Listener.Addr().String(), config)
+	if err != nil {
+		panic(err)
+	}
+
+	ts.Config.Handler = s
+	ts.Start()
+	s.server = ts
+
+	return s
+}
+
+// Close shuts down a server started with NewServer
+func (s *Server) Close() {
+	if s.server != nil {
+		s.server.Close()
+	}
+}
+
+// URL returns the issuer URL of the provider
+func (s *Server) URL() string {
+	return s.issuer
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// Endpoints returns the provider URLs for sso.WithEndpoints
+func (s *Server) Endpoints() sso.Endpoints {
+	return sso.Endpoints{
+		Issuer:       s.issuer,
+		Authorize:    s.issuer + sso.DefaultConfig.AuthorizeEndpoint,
+		Token:        s.issuer + sso.DefaultConfig.TokenEndpoint,
+		UserInfo:     s.issuer + sso.DefaultConfig.UserInfoEndpoint,
+		Revoke:       s.issuer + sso.DefaultConfig.RevokeEndpoint,
+		JWKS:         s.issuer + sso.DefaultConfig.JWKSEndpoint,
+		Unauthorized: s.issuer + sso.DefaultConfig.UnauthorizedURL,
+	}
+}
+
+// ClientOptions points an sso.OAuth2Client at this provider and keeps its verifiers
+// in memory, so no Redis is needed. Append sso.WithStore to use another store.
+func (s *Server) ClientOptions() []sso.ClientOption {
+	return []sso.ClientOption{
+		sso.WithEndpoints(s.Endpoints()),
+		sso.WithStore(store.NewMemoryStore()),
+	}
+}
+
+// AddUser adds or replaces an account
+func (s *Server) AddUser(user User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[user.Sub] = user
+}
+
+// Fail makes endpoint respond with the failure instead of its normal response
+func (s *Server) Fail(endpoint string, failure Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if failure.StatusCode == 0 {
+		failure.StatusCode = http.StatusInternalServerError
+	}
+	s.failures[endpoint] = &failure
+}
+
+// ClearFailures removes every injected failure
+func (s *Server) ClearFailures() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = map[string]*Failure{}
+}
+
+// Requests returns how many requests endpoint has received, including failed ones
+func (s *Server) Requests(endpoint string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[endpoint]
+}
+
+// ExpireTokens makes every issued access token expired, so the refresh flow can be exercised
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := time.Now().Add(-time.Second)
+	for _, g := range s.accessTokens {
+		g.expiresAt = expired
+	}
+}
+
+// Authorize plays the browser: it opens authURL as user sub (the first user when empty)
+// and returns the code and state the provider redirects back with.
+func (s *Server) Authorize(authURL, sub string) (code, state string, err error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	query := u.Query()
+	if sub != "" {
+		query.Set("login_hint", sub)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, sso.DefaultConfig.AuthorizeEndpoint+"?"+query.Encode(), nil)
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusFound {
+		return "", "", fmt.Errorf("ssotest: authorize returned status %d: %s", rec.Code, strings.TrimSpace(rec.Body.String()))
+	}
+
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		return "", "", err
+	}
+	if errCode := location.Query().Get("error"); errCode != "" {
+		return "", "", fmt.Errorf("ssotest: authorize failed: %s", errCode)
+	}
+
+	return location.Query().Get("code"), location.Query().Get("state"), nil
+}
+
+// handle registers an endpoint with request counting and failure injection
+func (s *Server) handle(pattern, endpoint string, handler http.HandlerFunc) {
+	s.handler.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if failure := s.nextFailure(endpoint); failure != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(failure.StatusCode)
+			fmt.Fprint(w, failure.Body)
+			return
+		}
+		handler(w, r)
+	})
+}
+
+// nextFailure counts the request and returns the injected failure, if any
+func (s *Server) nextFailure(endpoint string) *Failure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests[endpoint]++
+
+	failure, ok := s.failures[endpoint]
+	if !ok {
+		return nil
+	}
+	if failure.Times > 0 {
+		failure.Times--
+		if failure.Times == 0 {
+			delete(s.failures, endpoint)
+		}
+	}
+
+	result := *failure
+	return &result
+}
+
+// randomString returns n random bytes encoded as base64url
+func randomString(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryItem struct {
+	value     string
+	expiresAt time.Time
+}
+
+// MemoryStore keeps keys in process memory. Data is lost on restart and not shared
+// between instances, so it only suits single-instance deployments and tests.
+type MemoryStore struct {
+	mu          sync.Mutex
+	items       map[string]memoryItem
+	lastCleanup time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		items:       map[string]memoryItem{},
+		lastCleanup: time.Now(),
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[key]
+	if !ok || item.expired(time.Now()) {
+		return "", ErrNotFound
+	}
+	return item.value, nil
+}
+
+func (s *MemoryStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	item := memoryItem{value: value}
+	if ttl > 0 {
+		item.expiresAt = now.Add(ttl)
+	}
+	s.items[key] = item
+
+	// Purge expired keys on writes so the map does not grow without bound
+	if now.Sub(s.lastCleanup) > cleanupInterval {
+		for k, v := range s.items {
+			if v.expired(now) {
+				delete(s.items, k)
+			}
+		}
+		s.lastCleanup = now
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[key]
+	delete(s.items, key)
+	if !ok || item.expired(time.Now()) {
+		return "", ErrNotFound
+	}
+	return item.value, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		delete(s.items, key)
+	}
+	return nil
+}
+
+// expired reports whether the item has a ttl that has passed
+func (i memoryItem) expired(now time.Time) bool {
+	return !i.expiresAt.IsZero() && now.After(i.expiresAt)
+}
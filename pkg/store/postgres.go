@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// kvEntry is a row of the kv_store table (see migrations/0003_create_kv_store_table.sql)
+type kvEntry struct {
+	Key       string     `gorm:"column:key;primaryKey"`
+	Value     string     `gorm:"column:value"`
+	ExpiresAt *time.Time `gorm:"column:expires_at"`
+}
+
+func (kvEntry) TableName() string {
+	return "kv_store"
+}
+
+// PostgresStore keeps keys in the kv_store table, shared by every instance
+type PostgresStore struct {
+	db *gorm.DB
+
+	mu          sync.Mutex
+	lastCleanup time.Time
+}
+
+// NewPostgresStore creates a store on an open database connection
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{
+		db:          db,
+		lastCleanup: time.Now(),
+	}
+}
+
+func (s *PostgresStore) Get(ctx context.Context, key string) (string, error) {
+	var entry kvEntry
+	err := s.db.WithContext(ctx).
+		Where("key = ? AND (expires_at IS NULL OR expires_at > ?)", key, time.Now()).
+		Take(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return entry.Value, nil
+}
+
+func (s *PostgresStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	entry := kvEntry{Key: key, Value: value}
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		entry.ExpiresAt = &expiresAt
+	}
+
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "expires_at"}),
+	}).Create(&entry).Error
+	if err != nil {
+		return err
+	}
+
+	s.cleanup(ctx)
+
+	return nil
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string) (string, error) {
+	// DELETE ... RETURNING lets exactly one concurrent caller receive the row
+	var entries []kvEntry
+	err := s.db.WithContext(ctx).
+		Clauses(clause.Returning{}).
+		Where("key = ?", key).
+		Delete(&entries).Error
+	if err != nil {
+		return "", err
+	}
+
+	if len(entries) == 0 || (entries[0].ExpiresAt != nil && time.Now().After(*entries[0].ExpiresAt)) {
+		return "", ErrNotFound
+	}
+	return entries[0].Value, nil
+}
+
+func (s *PostgresStore) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).Where("key IN ?", keys).Delete(&kvEntry{}).Error
+}
+
+// cleanup purges expired rows at most once per cleanupInterval
+func (s *PostgresStore) cleanup(ctx context.Context) {
+	s.mu.Lock()
+	if time.Since(s.lastCleanup) < cleanupInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastCleanup = time.Now()
+	s.mu.Unlock()
+
+	s.db.WithContext(ctx).Where("expires_at <= ?", time.Now()).Delete(&kvEntry{})
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisStore keeps keys in Redis, shared by every instance
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore wraps a connected Redis client
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) (string, error) {
+	value, err := s.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", ErrNotFound
+	}
+	return value, err
+}
+
+func (s *RedisStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return s.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string) (string, error) {
+	// GET+DEL in MULTI instead of GETDEL so Redis < 6.2 keeps working
+	var get *redis.StringCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return "", err
+	}
+
+	value, err := get.Result()
+	if err == redis.Nil {
+		return "", ErrNotFound
+	}
+	return value, err
+}
+
+func (s *RedisStore) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return s.client.Del(ctx, keys...).Err()
+}
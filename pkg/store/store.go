@@ -0,0 +1,38 @@
+// Package store provides short-lived key/value storage for OAuth verifiers, states and sessions.
+// Redis and Postgres share data between instances; the memory store only suits a single instance and tests.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when a key does not exist or has expired
+var ErrNotFound = errors.New("store: key not found")
+
+// Store is a key/value store with per-key expiry
+type Store interface {
+	// Get returns the value of key, or ErrNotFound
+	Get(ctx context.Context, key string) (string, error)
+
+	// Set stores value under key for ttl
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+
+	// Take returns the value of key and deletes it atomically, or ErrNotFound.
+	// Only one of several concurrent callers receives the value.
+	Take(ctx context.Context, key string) (string, error)
+
+	// Delete removes the keys; missing keys are ignored
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Drivers accepted by the STORE_DRIVER setting
+const (
+	DriverRedis    = "redis"
+	DriverMemory   = "memory"
+	DriverPostgres = "postgres"
+)
+
+// cleanupInterval is how often the memory and Postgres stores purge expired keys
+const cleanupInterval = time.Minute
@@ -0,0 +1,442 @@
+package validator
+
+import (
+	"djiroutine-go-clean-architecture/pkg/errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// TagName is the struct tag read by Struct.
+//
+//	type Request struct {
+//		Page    *int    `json:"page" validate:"min=1"`
+//		Limit   *int    `json:"limit" validate:"min=1,max=200"`
+//		Email   string  `json:"email" validate:"required,email"`
+//		Outcome *string `json:"outcome" validate:"oneof=success failure"`
+//		From    *string `json:"from" validate:"date=2006-01-02"`
+//		To      *string `json:"to" validate:"date=rfc3339|2006-01-02"`
+//		Lat     float64 `json:"lat" validate:"lat"`
+//		Code    string  `json:"code" validate:"regex=^[A-Z]{3}$"`
+//	}
+//
+// Rules other than required are skipped for nil pointers and empty values, so optional
+// fields only need to be valid when they are sent. regex must be the last rule because
+// its pattern may contain commas. A tag with an unknown rule or a malformed parameter is
+// reported as an error by Struct and Fields instead of being skipped. validate:"-" skips
+// the field and the structs nested in it.
+const TagName = "validate"
+
+// nameTags are tried in order to find the name a field is reported under, so errors use the
+// name the client sent whether the struct was bound from JSON, query, path or headers
+var nameTags = []string{"json", "query", "param", "header", "schema"}
+
+// dateLayouts are the names accepted by the date rule besides a Go layout
+var dateLayouts = map[string]string{
+	"":         "2006-01-02",
+	"date":     "2006-01-02",
+	"datetime": "2006-01-02 15:04:05",
+	"rfc3339":  time.RFC3339,
+}
+
+type rule struct {
+	name  string
+	param string
+	// limit is the parsed parameter of min and max
+	limit float64
+	// re is the compiled parameter of regex
+	re *regexp.Regexp
+}
+
+var rulesCache sync.Map // map[string][]rule
+
+// Validatable is implemented by requests with rules that tags cannot express, such as a
+// whitelist of sort columns. Its errors are reported together with the tag errors.
+type Validatable interface {
+	ValidateFields() []errors.FieldError
+}
+
+// Struct validates v, a struct or pointer to struct, against its validate tags and returns
+// every failed field at once as a 400 *errors.AppError, or nil when v is valid. Invalid tags
+// are a 500 *errors.AppError.
+func Struct(v interface{}) error {
+	fields, err := Fields(v)
+	if err != nil {
+		return errors.InternalServerError("Invalid validation rules", err)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return errors.ValidationError(fields...)
+}
+
+// Fields validates v like Struct and returns the failed fields, empty when v is valid, or
+// an error naming the field whose validate tag is invalid
+func Fields(v interface{}) ([]errors.FieldError, error) {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil, nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	var fields []errors.FieldError
+	if err := validateStruct(value, "", &fields); err != nil {
+		return nil, err
+	}
+
+	if validatable, ok := v.(Validatable); ok {
+		for _, fieldErr := range validatable.ValidateFields() {
+			if !hasField(fields, fieldErr.Field) {
+				fields = append(fields, fieldErr)
+			}
+		}
+	}
+
+	return fields, nil
+}
+
+func hasField(fields []errors.FieldError, name string) bool {
+	for _, field := range fields {
+		if field.Field == name {
+			return true
+		}
+	}
+	return false
+}
+
+func validateStruct(value reflect.Value, prefix string, fields *[]errors.FieldError) error {
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		fieldValue := value.Field(i)
+
+		// Fields of an embedded struct are reported as if they were declared on the outer struct,
+		// also when the embedded type is unexported, as Bind fills them in that case too
+		if field.Anonymous && fieldValue.Kind() == reflect.Struct && field.Tag.Get(TagName) == "" {
+			if err := validateStruct(fieldValue, prefix, fields); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		// "-" skips the field, nested structs included
+		tag := field.Tag.Get(TagName)
+		if tag == "-" {
+			continue
+		}
+
+		name := prefix + fieldName(field)
+
+		if tag != "" {
+			rules, err := parseRules(tag)
+			if err != nil {
+				return fmt.Errorf("validator: invalid tag on %s.%s: %w", typ.Name(), field.Name, err)
+			}
+			if fieldErr := validateField(fieldValue, name, rules); fieldErr != nil {
+				*fields = append(*fields, *fieldErr)
+				continue
+			}
+		}
+
+		if err := dive(fieldValue, name, fields); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dive validates nested structs and slices of structs
+func dive(value reflect.Value, name string, fields *[]errors.FieldError) error {
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		if value.Type() == reflect.TypeOf(time.Time{}) {
+			return nil
+		}
+		return validateStruct(value, name+".", fields)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if err := dive(value.Index(i), fmt.Sprintf("%s[%d]", name, i), fields); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// fieldName returns the first name found in nameTags, or the Go field name
+func fieldName(field reflect.StructField) string {
+	for _, tag := range nameTags {
+		name := strings.Split(field.Tag.Get(tag), ",")[0]
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// parseRules parses a validate tag and checks every rule and parameter, so a malformed tag
+// fails on its first use instead of when a request happens to reach the rule
+func parseRules(tag string) ([]rule, error) {
+	if cached, ok := rulesCache.Load(tag); ok {
+		return cached.([]rule), nil
+	}
+
+	var rules []rule
+	rest := tag
+	for rest != "" {
+		part := rest
+		if strings.HasPrefix(rest, "regex=") {
+			rest = ""
+		} else if i := strings.Index(rest, ","); i != -1 {
+			part, rest = rest[:i], rest[i+1:]
+		} else {
+			rest = ""
+		}
+
+		name, param := part, ""
+		if i := strings.Index(part, "="); i != -1 {
+			name, param = part[:i], part[i+1:]
+		}
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+
+		r, err := newRule(name, param)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+
+	rulesCache.Store(tag, rules)
+	return rules, nil
+}
+
+func newRule(name, param string) (rule, error) {
+	r := rule{name: name, param: param}
+
+	switch name {
+	case "required", "email", "date", "lat", "long":
+
+	case "min", "max":
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return r, fmt.Errorf("invalid %s parameter %q", name, param)
+		}
+		r.limit = limit
+
+	case "oneof":
+		if len(strings.Fields(param)) == 0 {
+			return r, fmt.Errorf("oneof needs at least one option")
+		}
+
+	case "regex":
+		re, err := regexp.Compile(param)
+		if err != nil {
+			return r, fmt.Errorf("invalid regex parameter %q: %v", param, err)
+		}
+		r.re = re
+
+	default:
+		return r, fmt.Errorf("unknown rule %q", name)
+	}
+
+	return r, nil
+}
+
+func validateField(value reflect.Value, name string, rules []rule) *errors.FieldError {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			value = reflect.Value{}
+			break
+		}
+		value = value.Elem()
+	}
+
+	empty := !value.IsValid() || value.IsZero() || (hasLength(value) && value.Len() == 0)
+
+	for _, r := range rules {
+		if r.name == "required" {
+			if empty {
+				return fieldError(name, errors.CodeRequired, "required", "")
+			}
+			continue
+		}
+
+		// Optional fields are only checked when a value was sent; zero numbers count as sent
+		if !value.IsValid() || (hasLength(value) && value.Len() == 0) {
+			continue
+		}
+
+		if fieldErr := applyRule(value, name, r); fieldErr != nil {
+			return fieldErr
+		}
+	}
+
+	return nil
+}
+
+func applyRule(value reflect.Value, name string, r rule) *errors.FieldError {
+	switch r.name {
+	case "min", "max":
+		n, ok := measure(value)
+		if !ok {
+			return nil
+		}
+		if (r.name == "min" && n < r.limit) || (r.name == "max" && n > r.limit) {
+			rule := r.name
+			if value.Kind() == reflect.String {
+				rule += "_length"
+			} else if hasLength(value) {
+				rule += "_items"
+			}
+			return fieldError(name, errors.CodeOutOfRange, rule, r.param)
+		}
+
+	case "oneof":
+		options := strings.Fields(r.param)
+		actual := format(value)
+		for _, option := range options {
+			if strings.EqualFold(actual, option) {
+				return nil
+			}
+		}
+		return fieldError(name, errors.CodeInvalidValue, "oneof", strings.Join(options, ", "))
+
+	case "email":
+		if value.Kind() == reflect.String && !ValidateEmail(value.String()) {
+			return fieldError(name, errors.CodeInvalidFormat, "email", "")
+		}
+
+	case "date":
+		if value.Kind() == reflect.String && !matchDate(value.String(), r.param) {
+			return fieldError(name, errors.CodeInvalidFormat, "date", strings.Join(layouts(r.param), " | "))
+		}
+
+	case "lat", "long":
+		bound := 90.0
+		if r.name == "long" {
+			bound = 180
+		}
+		n, ok := measureNumber(value)
+		if !ok {
+			return fieldError(name, errors.CodeInvalidDataType, r.name, "")
+		}
+		if n < -bound || n > bound {
+			return fieldError(name, errors.CodeOutOfRange, "between", strconv.FormatFloat(bound, 'f', -1, 64))
+		}
+
+	case "regex":
+		if value.Kind() == reflect.String && !r.re.MatchString(value.String()) {
+			return fieldError(name, errors.CodeInvalidFormat, "regex", r.param)
+		}
+	}
+
+	return nil
+}
+
+// matchDate reports whether value matches one of the layouts of a date rule parameter, which
+// are separated by "|" and may be names from dateLayouts, e.g. "rfc3339|2006-01-02"
+func matchDate(value, param string) bool {
+	for _, layout := range layouts(param) {
+		if _, err := time.Parse(layout, value); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func layouts(param string) []string {
+	var res []string
+	for _, layout := range strings.Split(param, "|") {
+		if named, ok := dateLayouts[strings.ToLower(layout)]; ok {
+			layout = named
+		}
+		res = append(res, layout)
+	}
+	return res
+}
+
+// measure returns the number min and max compare: the value of numbers and the length
+// of strings, slices and maps
+func measure(value reflect.Value) (float64, bool) {
+	if hasLength(value) {
+		if value.Kind() == reflect.String {
+			return float64(utf8.RuneCountInString(value.String())), true
+		}
+		return float64(value.Len()), true
+	}
+	return measureNumber(value)
+}
+
+// measureNumber returns the value of numbers and of strings holding a number
+func measureNumber(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	case reflect.String:
+		n, err := strconv.ParseFloat(value.String(), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// format returns value as text without Interface, which fails for fields promoted from an
+// unexported embedded struct
+func format(value reflect.Value) string {
+	switch value.Kind() {
+	case reflect.String:
+		return value.String()
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(value.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 64)
+	}
+	if value.CanInterface() {
+		return fmt.Sprint(value.Interface())
+	}
+	return ""
+}
+
+func hasLength(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return true
+	}
+	return false
+}
+
+func fieldError(name, code, rule, param string) *errors.FieldError {
+	fieldErr := errors.NewFieldError(name, code, rule, param)
+	return &fieldErr
+}
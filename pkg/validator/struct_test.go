@@ -0,0 +1,218 @@
+package validator_test
+
+import (
+	"djiroutine-go-clean-architecture/pkg/errors"
+	"djiroutine-go-clean-architecture/pkg/validator"
+	"testing"
+)
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestFieldsRules(t *testing.T) {
+	type request struct {
+		Name    string   `json:"name" validate:"required,min=2,max=5"`
+		Page    *int     `json:"page" validate:"min=1,max=10"`
+		Tags    []string `json:"tags" validate:"min=2,max=3"`
+		Email   string   `json:"email" validate:"email"`
+		Outcome *string  `json:"outcome" validate:"oneof=success failure"`
+		From    *string  `json:"from" validate:"date=2006-01-02"`
+		To      string   `json:"to" validate:"date=rfc3339|2006-01-02"`
+		Lat     float64  `json:"lat" validate:"lat"`
+		Long    string   `json:"long" validate:"long"`
+		Code    string   `json:"code" validate:"regex=^[A-Z]{3}(,[A-Z]{3})?$"`
+	}
+	valid := func() request {
+		return request{Name: "abc"}
+	}
+
+	tests := []struct {
+		name  string
+		edit  func(r *request)
+		field string
+		code  string
+		rule  string
+	}{
+		{name: "valid", edit: func(r *request) {}},
+		{name: "required", edit: func(r *request) { r.Name = "" }, field: "name", code: errors.CodeRequired, rule: "required"},
+		{name: "min length", edit: func(r *request) { r.Name = "a" }, field: "name", code: errors.CodeOutOfRange, rule: "min_length"},
+		{name: "max length counts runes", edit: func(r *request) { r.Name = "ééééé" }},
+		{name: "max length", edit: func(r *request) { r.Name = "abcdef" }, field: "name", code: errors.CodeOutOfRange, rule: "max_length"},
+		{name: "min number", edit: func(r *request) { r.Page = intPtr(0) }, field: "page", code: errors.CodeOutOfRange, rule: "min"},
+		{name: "max number", edit: func(r *request) { r.Page = intPtr(11) }, field: "page", code: errors.CodeOutOfRange, rule: "max"},
+		{name: "number in range", edit: func(r *request) { r.Page = intPtr(10) }},
+		{name: "min items", edit: func(r *request) { r.Tags = []string{"a"} }, field: "tags", code: errors.CodeOutOfRange, rule: "min_items"},
+		{name: "max items", edit: func(r *request) { r.Tags = []string{"a", "b", "c", "d"} }, field: "tags", code: errors.CodeOutOfRange, rule: "max_items"},
+		{name: "email", edit: func(r *request) { r.Email = "not-an-email" }, field: "email", code: errors.CodeInvalidFormat, rule: "email"},
+		{name: "valid email", edit: func(r *request) { r.Email = "jane@example.com" }},
+		{name: "oneof", edit: func(r *request) { r.Outcome = stringPtr("maybe") }, field: "outcome", code: errors.CodeInvalidValue, rule: "oneof"},
+		{name: "oneof ignores case", edit: func(r *request) { r.Outcome = stringPtr("SUCCESS") }},
+		{name: "date", edit: func(r *request) { r.From = stringPtr("01/02/2024") }, field: "from", code: errors.CodeInvalidFormat, rule: "date"},
+		{name: "date second layout", edit: func(r *request) { r.To = "2024-01-02" }},
+		{name: "date named layout", edit: func(r *request) { r.To = "2024-01-02T15:04:05Z" }},
+		{name: "lat", edit: func(r *request) { r.Lat = 90.5 }, field: "lat", code: errors.CodeOutOfRange, rule: "between"},
+		{name: "long", edit: func(r *request) { r.Long = "-180.1" }, field: "long", code: errors.CodeOutOfRange, rule: "between"},
+		{name: "long not a number", edit: func(r *request) { r.Long = "east" }, field: "long", code: errors.CodeInvalidDataType, rule: "long"},
+		{name: "regex", edit: func(r *request) { r.Code = "abc" }, field: "code", code: errors.CodeInvalidFormat, rule: "regex"},
+		{name: "regex with comma", edit: func(r *request) { r.Code = "ABC,DEF" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid()
+			tt.edit(&r)
+
+			fields, err := validator.Fields(&r)
+			if err != nil {
+				t.Fatalf("Fields: %v", err)
+			}
+			if tt.field == "" {
+				if len(fields) != 0 {
+					t.Fatalf("fields = %+v, want none", fields)
+				}
+				return
+			}
+			if len(fields) != 1 {
+				t.Fatalf("fields = %+v, want one error on %s", fields, tt.field)
+			}
+			if got := fields[0]; got.Field != tt.field || got.Code != tt.code || got.Rule != tt.rule {
+				t.Errorf("field error = %s %s %s, want %s %s %s", got.Field, got.Code, got.Rule, tt.field, tt.code, tt.rule)
+			}
+		})
+	}
+}
+
+func TestFieldsEmptyValues(t *testing.T) {
+	type request struct {
+		Page     *int     `json:"page" validate:"min=1"`
+		Search   string   `json:"search" validate:"min=3"`
+		IDs      []int    `json:"ids" validate:"min=1"`
+		Limit    int      `json:"limit" validate:"min=1"`
+		Required *int     `json:"required" validate:"required"`
+		Tags     []string `json:"tags" validate:"required"`
+	}
+
+	tests := []struct {
+		name    string
+		request request
+		fields  []string
+	}{
+		{name: "nil pointer, empty string and empty slice skip rules", request: request{Required: intPtr(1), Tags: []string{"a"}, Limit: 1}},
+		{name: "zero number is checked", request: request{Required: intPtr(1), Tags: []string{"a"}}, fields: []string{"limit"}},
+		{name: "pointer to zero is sent", request: request{Page: intPtr(0), Required: intPtr(1), Tags: []string{"a"}, Limit: 1}, fields: []string{"page"}},
+		{name: "required nil pointer", request: request{Tags: []string{"a"}, Limit: 1}, fields: []string{"required"}},
+		{name: "required pointer to zero", request: request{Required: intPtr(0), Tags: []string{"a"}, Limit: 1}, fields: []string{"required"}},
+		{name: "required empty slice", request: request{Required: intPtr(1), Tags: []string{}, Limit: 1}, fields: []string{"tags"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := validator.Fields(tt.request)
+			if err != nil {
+				t.Fatalf("Fields: %v", err)
+			}
+			assertFields(t, fields, tt.fields)
+		})
+	}
+}
+
+func TestFieldsNested(t *testing.T) {
+	type Pagination struct {
+		Page int `query:"page" validate:"min=1"`
+	}
+	type item struct {
+		Code string `json:"code" validate:"required"`
+	}
+	type sorting struct {
+		Order string `query:"order" validate:"oneof=asc desc"`
+	}
+	type request struct {
+		Pagination
+		sorting
+		Owner *item  `json:"owner"`
+		Items []item `json:"items"`
+		Skip  item   `json:"skip" validate:"-"`
+	}
+
+	tests := []struct {
+		name    string
+		request request
+		fields  []string
+	}{
+		{name: "valid", request: request{Pagination: Pagination{Page: 1}, Items: []item{{Code: "a"}}}},
+		{name: "embedded fields keep their name", request: request{}, fields: []string{"page"}},
+		{name: "unexported embedded struct", request: request{Pagination: Pagination{Page: 1}, sorting: sorting{Order: "up"}}, fields: []string{"order"}},
+		{name: "nested pointer", request: request{Pagination: Pagination{Page: 1}, Owner: &item{}}, fields: []string{"owner.code"}},
+		{name: "slice of structs", request: request{Pagination: Pagination{Page: 1}, Items: []item{{Code: "a"}, {}}}, fields: []string{"items[1].code"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := validator.Fields(&tt.request)
+			if err != nil {
+				t.Fatalf("Fields: %v", err)
+			}
+			assertFields(t, fields, tt.fields)
+		})
+	}
+}
+
+func TestStructInvalidTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		request interface{}
+	}{
+		{name: "malformed min", request: &struct {
+			Page int `validate:"min=one"`
+		}{Page: 1}},
+		{name: "malformed max", request: &struct {
+			Name string `validate:"max="`
+		}{Name: "a"}},
+		{name: "unknown rule", request: &struct {
+			Name string `validate:"uuid"`
+		}{Name: "a"}},
+		{name: "bad regex", request: &struct {
+			Code string `validate:"regex=[A-Z"`
+		}{Code: "A"}},
+		{name: "oneof without options", request: &struct {
+			Outcome string `validate:"oneof="`
+		}{Outcome: "a"}},
+		{name: "checked even when empty", request: &struct {
+			Page *int `validate:"min=one"`
+		}{}},
+		{name: "nested", request: &struct {
+			Items []struct {
+				Code string `validate:"regex=("`
+			}
+		}{Items: make([]struct {
+			Code string `validate:"regex=("`
+		}, 1)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.Struct(tt.request)
+			appErr, ok := err.(*errors.AppError)
+			if !ok || appErr.Code != 500 {
+				t.Fatalf("Struct error = %v, want a 500 *errors.AppError", err)
+			}
+		})
+	}
+}
+
+func assertFields(t *testing.T, fields []errors.FieldError, want []string) {
+	t.Helper()
+	if len(fields) != len(want) {
+		t.Fatalf("fields = %+v, want %v", fields, want)
+	}
+	for i, field := range fields {
+		if field.Field != want[i] {
+			t.Errorf("fields[%d] = %s, want %s", i, field.Field, want[i])
+		}
+	}
+}